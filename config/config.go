@@ -0,0 +1,736 @@
+// Package config reads pggo config files and connects to the database they
+// describe, so programs embedding the migrate package can use the same
+// pggo.conf, profiles and SSH tunnel as the pggo command.
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/swelf19/pggo/v2/migrate"
+)
+
+// Config holds the connection and migrator settings of a pggo config file.
+type Config struct {
+	ConnConfig      pgx.ConnConfig
+	PasswordFile    string
+	PasswordCommand string
+	SslMode         string
+	SslRootCert     string
+	SslCert         string
+	SslKey          string
+	SslPassword     string
+	SslSNI          string
+	VersionTable    string
+	SeedTable       string
+	TemplatePaths   []string
+	NewTimestamp    bool // NewTimestamp makes pggo new prefix migrations with a UTC timestamp
+	NewPadding      int  // NewPadding is the zero padded width of sequence numbers for pggo new
+	LintDisable     []string
+	Data            map[string]interface{}
+	SSHConnConfig   SSHConnConfig
+
+	// hosts are the hosts after ConnConfig.Host of a multi-host url.
+	hosts []*pgconn.FallbackConfig
+	// tunnel is the SSH tunnel opened by Connect.
+	tunnel *SSHTunnel
+}
+
+func (c *Config) Validate() error {
+	if c.ConnConfig.Host == "" {
+		return errors.New("Config must contain host but it does not")
+	}
+
+	if c.ConnConfig.Database == "" {
+		return errors.New("Config must contain database but it does not")
+	}
+
+	switch c.SslMode {
+	case "", "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		// okay
+	default:
+		return errors.New("sslmode is invalid")
+	}
+
+	if (c.SslCert == "") != (c.SslKey == "") {
+		return errors.New("Config must contain both sslcert and sslkey or neither")
+	}
+
+	switch c.SslSNI {
+	case "", "0", "1":
+		// okay
+	default:
+		return errors.New("sslsni is invalid")
+	}
+
+	return nil
+}
+
+// Connect connects to the database, through the SSH tunnel if one is
+// configured. The tunnel is opened by the first call and shared by later ones
+// and copies of c. Close closes it.
+func (c *Config) Connect(ctx context.Context) (*pgx.Conn, error) {
+	if err := c.resolvePasswords(); err != nil {
+		return nil, err
+	}
+
+	if c.SSHConnConfig.Host != "" {
+		if c.tunnel == nil {
+			tunnel, err := NewSSHTunnel(&c.SSHConnConfig)
+			if err != nil {
+				return nil, err
+			}
+			c.tunnel = tunnel
+		}
+
+		tunnel := c.tunnel
+		c.ConnConfig.DialFunc = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			return tunnel.Dial(network, addr)
+		}
+	}
+
+	if err := c.applyTLS(); err != nil {
+		return nil, err
+	}
+
+	return pgx.ConnectConfig(ctx, &c.ConnConfig)
+}
+
+// Close closes the SSH tunnel opened by Connect, if any. Connections made
+// through it should be closed first.
+func (c *Config) Close() error {
+	if c.tunnel == nil {
+		return nil
+	}
+	err := c.tunnel.Close()
+	c.tunnel = nil
+	return err
+}
+
+// Option configures Load.
+type Option func(*loadOptions)
+
+type loadOptions struct {
+	path      string
+	profile   string
+	environ   []string
+	overrides []func(*Config) error
+}
+
+// WithFile reads the config file at path instead of ./pggo.conf. An empty
+// path keeps the default.
+func WithFile(path string) Option {
+	return func(o *loadOptions) {
+		o.path = path
+	}
+}
+
+// WithProfile applies the profile sections of profile, e.g. [database.staging],
+// instead of the ones named by PGGO_ENV.
+func WithProfile(profile string) Option {
+	return func(o *loadOptions) {
+		o.profile = profile
+	}
+}
+
+// WithEnviron reads DATABASE_URL, PGGO_ENV, the PGGO_DATA_* variables and the
+// env of config file templates from environ instead of the process
+// environment. The PG* variables are always read from the process environment
+// by pgx.
+func WithEnviron(environ []string) Option {
+	return func(o *loadOptions) {
+		o.environ = environ
+	}
+}
+
+// WithURL replaces the connection settings of the config file with the ones
+// parsed from url.
+func WithURL(url string) Option {
+	return WithOverride(func(c *Config) error {
+		return c.SetURL(url)
+	})
+}
+
+// WithData sets template data, overriding the config file and the PGGO_DATA_*
+// variables.
+func WithData(data map[string]interface{}) Option {
+	return WithOverride(func(c *Config) error {
+		for key, value := range data {
+			c.Data[key] = value
+		}
+		return nil
+	})
+}
+
+// WithOverride calls f to change the config after the config file and the
+// environment are applied, e.g. to apply command line arguments. Overrides
+// run in the order given.
+func WithOverride(f func(*Config) error) Option {
+	return func(o *loadOptions) {
+		o.overrides = append(o.overrides, f)
+	}
+}
+
+// Load returns the config pggo uses. DATABASE_URL is applied first, then the
+// config file, ./pggo.conf if it exists unless WithFile is given, with the
+// profile from WithProfile or PGGO_ENV. The PGGO_DATA_* environment variables
+// and the overrides follow. Settings missing from all of them fall back to the
+// PG* environment variables. DATABASE_URL is ignored when the config file or
+// overrides set another host.
+func Load(opts ...Option) (*Config, error) {
+	o := &loadOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.environ == nil {
+		o.environ = os.Environ()
+	}
+	env := environMap(o.environ)
+
+	config, urlHost, err := load(o, env, env["DATABASE_URL"])
+	if err != nil {
+		return nil, err
+	}
+	// DATABASE_URL describes one server. When the config file or overrides
+	// point the connection at another host its user, password and TLS
+	// settings must not follow, so the config is loaded again without it.
+	if urlHost != "" && config.ConnConfig.Host != urlHost {
+		config, _, err = load(o, env, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.SSHConnConfig.Host != "" {
+		err := applySSHConfig(&config.SSHConnConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.SSHConnConfig.User == "" {
+		user, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		config.SSHConnConfig.User = user.Username
+	}
+
+	if config.SSHConnConfig.Port == "" {
+		config.SSHConnConfig.Port = "ssh"
+	}
+
+	return config, nil
+}
+
+// load reads the config file and applies the overrides on top of
+// databaseURL. It returns the host named by databaseURL, if any.
+func load(o *loadOptions, env map[string]string, databaseURL string) (*Config, string, error) {
+	connConfig, err := pgx.ParseConfig("")
+	if err != nil {
+		return nil, "", err
+	}
+	config := &Config{ConnConfig: *connConfig, VersionTable: "public.schema_version", SeedTable: "public.schema_seed", NewPadding: 3, Data: make(map[string]interface{})}
+	config.SSHConnConfig.KeepaliveInterval = 30 * time.Second
+
+	var urlHost string
+	if databaseURL != "" {
+		if err := config.SetURL(databaseURL); err != nil {
+			return nil, "", fmt.Errorf("DATABASE_URL: %v", err)
+		}
+		if _, ok := connSettings(databaseURL)["host"]; ok {
+			urlHost = config.ConnConfig.Host
+		}
+	}
+
+	// Set default config path only if it exists
+	path := o.path
+	if path == "" {
+		if _, err := os.Stat("./pggo.conf"); err == nil {
+			path = "./pggo.conf"
+		}
+	}
+
+	profile := o.profile
+	if profile == "" {
+		profile = env["PGGO_ENV"]
+	}
+
+	if path != "" {
+		err := appendConfigFromFile(config, path, profile, o.environ)
+		if err != nil {
+			return nil, "", err
+		}
+	} else if profile != "" {
+		return nil, "", fmt.Errorf("profile %s requires a config file", profile)
+	}
+
+	appendDataFromEnv(config, o.environ)
+
+	for _, override := range o.overrides {
+		err := override(config)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	return config, urlHost, nil
+}
+
+// environMap splits environ into a map of variable names to values.
+func environMap(environ []string) map[string]string {
+	env := make(map[string]string, len(environ))
+	for _, s := range environ {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+	return env
+}
+
+// profileSections are the config file sections a profile can override. A
+// profile section is named after the base section, e.g.
+// [database.staging].
+var profileSections = []string{"database", "data", "ssh-tunnel"}
+
+// readConfigFile reads the config file at path. When profile is not empty the
+// values of its profile sections override the values of the base sections.
+// The config file template sees environ as env.
+func readConfigFile(path, profile string, environ []string) (configFile, error) {
+	env := environMap(environ)
+
+	fileBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	confTemplate, err := template.New("conf").Funcs(migrate.TemplateFuncs()).Parse(string(fileBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = confTemplate.Execute(&buf, map[string]interface{}{
+		"env": env,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := parseConfigFile(path, buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	if profile != "" {
+		err = applyProfile(file, profile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	return file, nil
+}
+
+// appendConfigFromFile applies the config file at path to config. See
+// readConfigFile for profile and environ.
+func appendConfigFromFile(config *Config, path, profile string, environ []string) error {
+	file, err := readConfigFile(path, profile, environ)
+	if err != nil {
+		return err
+	}
+
+	// The url goes first so that the other settings override its parts.
+	if url, ok := file.Get("database", "url"); ok {
+		if err := config.SetURL(url); err != nil {
+			return fmt.Errorf("%s: url: %v", path, err)
+		}
+	}
+
+	if host, ok := file.Get("database", "host"); ok {
+		config.SetHost(host)
+	}
+
+	// For backwards compatibility if host isn't set look for socket.
+	if config.ConnConfig.Host == "" {
+		if socket, ok := file.Get("database", "socket"); ok {
+			config.SetHost(socket)
+		}
+	}
+
+	if p, ok := file.Get("database", "port"); ok {
+		n, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return err
+		}
+		config.ConnConfig.Port = uint16(n)
+	}
+	if database, ok := file.Get("database", "database"); ok {
+		config.ConnConfig.Database = database
+	}
+
+	if user, ok := file.Get("database", "user"); ok {
+		config.ConnConfig.User = user
+	}
+	if password, ok := file.Get("database", "password"); ok {
+		config.ConnConfig.Password = password
+	}
+	if passwordFile, ok := file.Get("database", "password_file"); ok {
+		config.PasswordFile = passwordFile
+	}
+	if passwordCommand, ok := file.Get("database", "password_command"); ok {
+		config.PasswordCommand = passwordCommand
+	}
+
+	if vt, ok := file.Get("database", "version_table"); ok {
+		config.VersionTable = vt
+	}
+
+	if st, ok := file.Get("database", "seed_table"); ok {
+		config.SeedTable = st
+	}
+
+	if templatePaths, ok := file.Get("database", "template_paths"); ok {
+		config.TemplatePaths = SplitList(templatePaths)
+	}
+
+	if lintDisable, ok := file.Get("database", "lint_disable"); ok {
+		config.LintDisable = SplitList(lintDisable)
+	}
+
+	if err := appendNewSettings(config, file); err != nil {
+		return err
+	}
+
+	if sslmode, ok := file.Get("database", "sslmode"); ok {
+		config.SslMode = sslmode
+	}
+
+	if sslrootcert, ok := file.Get("database", "sslrootcert"); ok {
+		config.SslRootCert = sslrootcert
+	}
+
+	if sslcert, ok := file.Get("database", "sslcert"); ok {
+		config.SslCert = sslcert
+	}
+
+	if sslkey, ok := file.Get("database", "sslkey"); ok {
+		config.SslKey = sslkey
+	}
+
+	if sslpassword, ok := file.Get("database", "sslpassword"); ok {
+		config.SslPassword = sslpassword
+	}
+
+	if sslsni, ok := file.Get("database", "sslsni"); ok {
+		config.SslSNI = sslsni
+	}
+
+	if config.Data == nil {
+		config.Data = make(map[string]interface{})
+	}
+	for key, value := range file["data"] {
+		config.Data[key] = value
+	}
+
+	if host, ok := file.Get("ssh-tunnel", "host"); ok {
+		config.SSHConnConfig.Host = host
+	}
+
+	if port, ok := file.Get("ssh-tunnel", "port"); ok {
+		config.SSHConnConfig.Port = port
+	}
+
+	if user, ok := file.Get("ssh-tunnel", "user"); ok {
+		config.SSHConnConfig.User = user
+	}
+
+	if password, ok := file.Get("ssh-tunnel", "password"); ok {
+		config.SSHConnConfig.Password = password
+	}
+
+	if passwordFile, ok := file.Get("ssh-tunnel", "password_file"); ok {
+		config.SSHConnConfig.PasswordFile = passwordFile
+	}
+
+	if passwordCommand, ok := file.Get("ssh-tunnel", "password_command"); ok {
+		config.SSHConnConfig.PasswordCommand = passwordCommand
+	}
+
+	if identityFile, ok := file.Get("ssh-tunnel", "identity_file"); ok {
+		config.SSHConnConfig.IdentityFile = identityFile
+	}
+
+	if passphrase, ok := file.Get("ssh-tunnel", "identity_passphrase"); ok {
+		config.SSHConnConfig.IdentityPassphrase = passphrase
+	}
+
+	if knownHosts, ok := file.Get("ssh-tunnel", "known_hosts"); ok {
+		config.SSHConnConfig.KnownHostsFile = knownHosts
+	}
+
+	if jump, ok := file.Get("ssh-tunnel", "jump"); ok {
+		config.SSHConnConfig.Jump = SplitList(jump)
+	}
+
+	if interval, ok := file.Get("ssh-tunnel", "keepalive_interval"); ok {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			return fmt.Errorf("%s: keepalive_interval: %v", path, err)
+		}
+		config.SSHConnConfig.KeepaliveInterval = d
+	}
+
+	if insecure, ok := file.Get("ssh-tunnel", "insecure_ignore_host_key"); ok {
+		b, err := strconv.ParseBool(insecure)
+		if err != nil {
+			return fmt.Errorf("%s: insecure_ignore_host_key: %v", path, err)
+		}
+		config.SSHConnConfig.InsecureIgnoreHostKey = b
+	}
+
+	return nil
+}
+
+// applyProfile merges the sections of profile into their base sections.
+func applyProfile(file configFile, profile string) error {
+	found := false
+	for _, name := range profileSections {
+		values, ok := file[name+"."+profile]
+		if !ok {
+			continue
+		}
+		found = true
+
+		section := file.Section(name)
+		for key, value := range values {
+			section[key] = value
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("profile %s not found", profile)
+	}
+	return nil
+}
+
+// appendNewSettings applies the settings of pggo new in file to config.
+func appendNewSettings(config *Config, file configFile) error {
+	if prefix, ok := file.Get("database", "new_prefix"); ok {
+		switch prefix {
+		case "sequence":
+			config.NewTimestamp = false
+		case "timestamp":
+			config.NewTimestamp = true
+		default:
+			return fmt.Errorf("new_prefix must be sequence or timestamp: %s", prefix)
+		}
+	}
+
+	if padding, ok := file.Get("database", "new_padding"); ok {
+		n, err := strconv.Atoi(padding)
+		if err != nil || n < 1 {
+			return fmt.Errorf("new_padding must be a positive number: %s", padding)
+		}
+		config.NewPadding = n
+	}
+
+	return nil
+}
+
+// LoadNewSettings returns a config with only the settings of pggo new,
+// new_prefix and new_padding, read from the config file Load would read.
+// Connection settings are not read, so they cannot make it fail.
+func LoadNewSettings(opts ...Option) (*Config, error) {
+	o := &loadOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.environ == nil {
+		o.environ = os.Environ()
+	}
+
+	config := &Config{NewPadding: 3}
+
+	path := o.path
+	if path == "" {
+		if _, err := os.Stat("./pggo.conf"); err != nil {
+			return config, nil
+		}
+		path = "./pggo.conf"
+	}
+
+	profile := o.profile
+	if profile == "" {
+		profile = environMap(o.environ)["PGGO_ENV"]
+	}
+
+	file, err := readConfigFile(path, profile, o.environ)
+	if err != nil {
+		return nil, err
+	}
+	if err := appendNewSettings(config, file); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// SetURL sets the connection settings given in url, a connection URL or DSN.
+// Settings missing from url are left as they are.
+func (c *Config) SetURL(url string) error {
+	connConfig, err := pgx.ParseConfig(url)
+	if err != nil {
+		return err
+	}
+	settings := connSettings(url)
+
+	if _, ok := settings["host"]; ok {
+		c.SetHost(connConfig.Host)
+		// Keep the other hosts of a multi-host url to try in order.
+		for _, fallback := range connConfig.Fallbacks {
+			if fallback.Host == connConfig.Host && fallback.Port == connConfig.Port {
+				continue
+			}
+			if n := len(c.hosts); n > 0 && c.hosts[n-1].Host == fallback.Host && c.hosts[n-1].Port == fallback.Port {
+				continue
+			}
+			c.hosts = append(c.hosts, &pgconn.FallbackConfig{Host: fallback.Host, Port: fallback.Port})
+		}
+	}
+	if _, ok := settings["port"]; ok {
+		c.ConnConfig.Port = connConfig.Port
+	}
+	if _, ok := settings["dbname"]; ok {
+		c.ConnConfig.Database = connConfig.Database
+	}
+	if _, ok := settings["user"]; ok {
+		c.ConnConfig.User = connConfig.User
+	}
+	if _, ok := settings["password"]; ok {
+		c.ConnConfig.Password = connConfig.Password
+	}
+	if _, ok := settings["connect_timeout"]; ok {
+		// pgx applies connect_timeout in its dialer.
+		c.ConnConfig.DialFunc = connConfig.DialFunc
+	}
+	if _, ok := settings["target_session_attrs"]; ok {
+		c.ConnConfig.ValidateConnect = connConfig.ValidateConnect
+	}
+
+	for key, value := range connConfig.RuntimeParams {
+		// pgx does not know these settings and would send them to the server
+		// as run-time parameters.
+		if key == "sslpassword" || key == "sslsni" {
+			continue
+		}
+		if _, ok := settings[key]; !ok {
+			continue
+		}
+		if c.ConnConfig.RuntimeParams == nil {
+			c.ConnConfig.RuntimeParams = make(map[string]string)
+		}
+		c.ConnConfig.RuntimeParams[key] = value
+	}
+
+	c.urlTLSSettings(settings)
+	return nil
+}
+
+// SetHost points the connection at host. The other hosts of a multi-host url
+// are dropped. Connect derives the TLS server name and the fallbacks from the
+// host, so the ones pgx derived for the previous host are dropped as well.
+func (c *Config) SetHost(host string) {
+	c.ConnConfig.Host = host
+	c.ConnConfig.Fallbacks = nil
+	c.hosts = nil
+	if c.ConnConfig.TLSConfig != nil && c.ConnConfig.TLSConfig.ServerName != "" {
+		c.ConnConfig.TLSConfig = c.ConnConfig.TLSConfig.Clone()
+		c.ConnConfig.TLSConfig.ServerName = host
+	}
+}
+
+// connSettings returns the settings given in a connection URL or DSN by their
+// DSN key, e.g. dbname for the path of a URL.
+func connSettings(connString string) map[string]string {
+	settings := make(map[string]string)
+	if u, err := url.Parse(connString); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		if u.User != nil {
+			if user := u.User.Username(); user != "" {
+				settings["user"] = user
+			}
+			if password, ok := u.User.Password(); ok {
+				settings["password"] = password
+			}
+		}
+		if u.Host != "" {
+			settings["host"] = u.Host
+			for _, host := range strings.Split(u.Host, ",") {
+				if _, port, err := net.SplitHostPort(host); err == nil {
+					settings["port"] = port
+				}
+			}
+		}
+		if database := strings.TrimLeft(u.Path, "/"); database != "" {
+			settings["dbname"] = database
+		}
+		for key, values := range u.Query() {
+			settings[key] = values[0]
+		}
+		return settings
+	}
+
+	for _, pair := range strings.Fields(connString) {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) == 2 {
+			settings[parts[0]] = strings.Trim(parts[1], "'")
+		}
+	}
+	return settings
+}
+
+// MigratorOptions returns the options for migrators and seeders built from c.
+func (c *Config) MigratorOptions() *migrate.MigratorOptions {
+	return &migrate.MigratorOptions{TemplatePaths: c.TemplatePaths}
+}
+
+// dataEnvPrefix is the prefix of environment variables imported into the
+// template data. The rest of the variable name, lower cased, is the key.
+const dataEnvPrefix = "PGGO_DATA_"
+
+// appendDataFromEnv adds the PGGO_DATA_* variables of environ to the
+// template data, e.g. PGGO_DATA_APP_USER=joe sets app_user.
+func appendDataFromEnv(config *Config, environ []string) {
+	for _, s := range environ {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], dataEnvPrefix) || parts[0] == dataEnvPrefix {
+			continue
+		}
+		config.Data[strings.ToLower(strings.TrimPrefix(parts[0], dataEnvPrefix))] = parts[1]
+	}
+}
+
+// SplitList splits a comma separated list as used in config files and trims
+// its items.
+func SplitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
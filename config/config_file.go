@@ -0,0 +1,130 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	ini "github.com/vaughan0/go-ini"
+	"gopkg.in/yaml.v2"
+)
+
+// configFile holds the sections of a config file. INI files only have string
+// values while the other formats may nest maps and lists in any section.
+type configFile map[string]map[string]interface{}
+
+// parseConfigFile parses buf in the format indicated by the extension of
+// path. YAML, TOML and JSON are recognized, anything else is read as INI.
+func parseConfigFile(path string, buf []byte) (configFile, error) {
+	var doc map[string]interface{}
+	var err error
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var raw map[interface{}]interface{}
+		err = yaml.Unmarshal(buf, &raw)
+		if err == nil {
+			doc, _ = normalizeConfigValue(raw).(map[string]interface{})
+		}
+	case ".toml":
+		err = toml.Unmarshal(buf, &doc)
+	case ".json":
+		// Keep numbers as written instead of converting them to float64.
+		decoder := json.NewDecoder(bytes.NewReader(buf))
+		decoder.UseNumber()
+		err = decoder.Decode(&doc)
+	default:
+		iniFile, err := ini.Load(bytes.NewReader(buf))
+		if err != nil {
+			return nil, err
+		}
+
+		file := make(configFile)
+		for name, section := range iniFile {
+			file[name] = make(map[string]interface{})
+			for key, value := range section {
+				file[name][key] = value
+			}
+		}
+		return file, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file := make(configFile)
+	for name, value := range doc {
+		switch section := normalizeConfigValue(value).(type) {
+		case map[string]interface{}:
+			file[name] = section
+		case nil:
+			file[name] = make(map[string]interface{})
+		default:
+			return nil, fmt.Errorf("section %s must be a map", name)
+		}
+	}
+	return file, nil
+}
+
+// normalizeConfigValue converts the map[interface{}]interface{} values
+// produced by the YAML decoder to map[string]interface{} so every format
+// reaches templates with the same types.
+func normalizeConfigValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = normalizeConfigValue(value)
+		}
+		return m
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = normalizeConfigValue(value)
+		}
+		return v
+	case []interface{}:
+		for i, value := range v {
+			v[i] = normalizeConfigValue(value)
+		}
+		return v
+	case []map[string]interface{}:
+		s := make([]interface{}, len(v))
+		for i, value := range v {
+			s[i] = normalizeConfigValue(value)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// Get returns the value of key in section as a string. Numbers and booleans
+// are formatted, maps and lists are not considered a value.
+func (f configFile) Get(section, key string) (string, bool) {
+	value, ok := f[section][key]
+	if !ok || value == nil {
+		return "", false
+	}
+
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return "", false
+	case string:
+		return value.(string), true
+	default:
+		return fmt.Sprint(value), true
+	}
+}
+
+// Section returns the named section, creating it if it does not exist.
+func (f configFile) Section(name string) map[string]interface{} {
+	section, ok := f[name]
+	if !ok {
+		section = make(map[string]interface{})
+		f[name] = section
+	}
+	return section
+}
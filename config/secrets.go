@@ -0,0 +1,100 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgpassfile"
+)
+
+// readSecret returns the contents of file if it is set, otherwise the output
+// of command. Trailing newlines are removed.
+func readSecret(file, command string) (string, error) {
+	if file != "" {
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(buf), "\r\n"), nil
+	}
+
+	if command != "" {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", command)
+		} else {
+			cmd = exec.Command("sh", "-c", command)
+		}
+
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("password command failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return strings.TrimRight(string(output), "\r\n"), nil
+	}
+
+	return "", nil
+}
+
+// lookupPgpass returns the password for the connection from the file named by
+// PGPASSFILE or ~/.pgpass. It returns an empty string if there is no such
+// file or no matching entry.
+func lookupPgpass(host string, port uint16, database, username string) string {
+	path := os.Getenv("PGPASSFILE")
+	if path == "" {
+		u, err := user.Current()
+		if err != nil {
+			return ""
+		}
+		path = filepath.Join(u.HomeDir, ".pgpass")
+	}
+
+	passfile, err := pgpassfile.ReadPassfile(path)
+	if err != nil {
+		return ""
+	}
+
+	// Unix domain socket connections are matched as localhost, like libpq.
+	if strings.HasPrefix(host, "/") {
+		host = "localhost"
+	}
+
+	return passfile.FindPassword(host, strconv.Itoa(int(port)), database, username)
+}
+
+// resolvePasswords reads the database and SSH passwords from their password
+// file or command. A password file or command takes precedence over a
+// password given directly, as command line arguments clear whichever of them
+// they do not set. A missing database password is finally looked up in the
+// pgpass file.
+func (c *Config) resolvePasswords() error {
+	var err error
+	if c.PasswordFile != "" || c.PasswordCommand != "" {
+		c.ConnConfig.Password, err = readSecret(c.PasswordFile, c.PasswordCommand)
+		if err != nil {
+			return fmt.Errorf("database password: %v", err)
+		}
+	}
+	if c.ConnConfig.Password == "" {
+		c.ConnConfig.Password = lookupPgpass(c.ConnConfig.Host, c.ConnConfig.Port, c.ConnConfig.Database, c.ConnConfig.User)
+	}
+
+	if c.SSHConnConfig.Host != "" && (c.SSHConnConfig.PasswordFile != "" || c.SSHConnConfig.PasswordCommand != "") {
+		c.SSHConnConfig.Password, err = readSecret(c.SSHConnConfig.PasswordFile, c.SSHConnConfig.PasswordCommand)
+		if err != nil {
+			return fmt.Errorf("SSH password: %v", err)
+		}
+	}
+
+	return nil
+}
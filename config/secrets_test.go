@@ -0,0 +1,87 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pggo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "password")
+	err = ioutil.WriteFile(path, []byte("secret\n"), 0600)
+	require.NoError(t, err)
+
+	password, err := readSecret(path, "echo ignored")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", password)
+
+	password, err = readSecret("", "")
+	require.NoError(t, err)
+	assert.Equal(t, "", password)
+
+	_, err = readSecret(filepath.Join(dir, "missing"), "")
+	assert.Error(t, err)
+
+	if runtime.GOOS != "windows" {
+		password, err = readSecret("", "echo from-command")
+		require.NoError(t, err)
+		assert.Equal(t, "from-command", password)
+
+		_, err = readSecret("", "exit 3")
+		assert.Error(t, err)
+	}
+}
+
+func TestLookupPgpass(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pggo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "pgpass")
+	err = ioutil.WriteFile(path, []byte("db.example.com:5432:app:migrator:s3cret\n*:*:*:*:fallback\n"), 0600)
+	require.NoError(t, err)
+
+	defer os.Setenv("PGPASSFILE", os.Getenv("PGPASSFILE"))
+	os.Setenv("PGPASSFILE", path)
+
+	assert.Equal(t, "s3cret", lookupPgpass("db.example.com", 5432, "app", "migrator"))
+	assert.Equal(t, "fallback", lookupPgpass("other.example.com", 5432, "app", "migrator"))
+}
+
+func TestResolvePasswords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pggo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "pggo.conf")
+	err = ioutil.WriteFile(confPath, []byte("[database]\nhost = localhost\ndatabase = app\npassword = inline\n\n[ssh-tunnel]\nhost = bastion\npassword = inline\n"), 0600)
+	require.NoError(t, err)
+	passwordPath := filepath.Join(dir, "password")
+	err = ioutil.WriteFile(passwordPath, []byte("from-file\n"), 0600)
+	require.NoError(t, err)
+
+	config, err := Load(WithFile(confPath), WithEnviron([]string{}))
+	require.NoError(t, err)
+	require.NoError(t, config.resolvePasswords())
+	assert.Equal(t, "inline", config.ConnConfig.Password)
+	assert.Equal(t, "inline", config.SSHConnConfig.Password)
+
+	config, err = Load(WithFile(confPath), WithEnviron([]string{}), WithOverride(func(c *Config) error {
+		c.PasswordFile = passwordPath
+		c.SSHConnConfig.PasswordFile = passwordPath
+		return nil
+	}))
+	require.NoError(t, err)
+	require.NoError(t, config.resolvePasswords())
+	assert.Equal(t, "from-file", config.ConnConfig.Password)
+	assert.Equal(t, "from-file", config.SSHConnConfig.Password)
+}
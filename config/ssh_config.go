@@ -0,0 +1,112 @@
+package config
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sshConfigHost holds the settings of an OpenSSH client config file that
+// apply to a host.
+type sshConfigHost struct {
+	HostName     string
+	Port         string
+	User         string
+	IdentityFile string
+}
+
+// readSSHConfig reads the settings for host from the OpenSSH client config
+// file at path. Only the Host, HostName, Port, User and IdentityFile keywords
+// are understood. As in OpenSSH, the first value found for a keyword wins.
+func readSSHConfig(path, host string) (sshConfigHost, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return sshConfigHost{}, err
+	}
+	defer file.Close()
+
+	return parseSSHConfig(file, host)
+}
+
+func parseSSHConfig(r io.Reader, host string) (sshConfigHost, error) {
+	var result sshConfigHost
+	matching := true
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		keyword, args := splitSSHConfigLine(line)
+		switch strings.ToLower(keyword) {
+		case "host":
+			matching = matchSSHHostPatterns(strings.Fields(args), host)
+		case "match":
+			// Match blocks are not supported, skip their settings.
+			matching = false
+		case "hostname":
+			if matching && result.HostName == "" {
+				result.HostName = args
+			}
+		case "port":
+			if matching && result.Port == "" {
+				result.Port = args
+			}
+		case "user":
+			if matching && result.User == "" {
+				result.User = args
+			}
+		case "identityfile":
+			if matching && result.IdentityFile == "" {
+				result.IdentityFile = expandHome(strings.Trim(args, `"`))
+			}
+		}
+	}
+
+	return result, scanner.Err()
+}
+
+// splitSSHConfigLine splits a config line into its keyword and arguments. The
+// keyword may be separated from the arguments by whitespace or an equals sign.
+func splitSSHConfigLine(line string) (string, string) {
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return line, ""
+	}
+	return line[:i], strings.TrimSpace(strings.TrimLeft(line[i:], " \t="))
+}
+
+// matchSSHHostPatterns reports whether host matches the patterns of a Host
+// line. A negated pattern that matches excludes the host.
+func matchSSHHostPatterns(patterns []string, host string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+		if ok, _ := filepath.Match(pattern, host); ok {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
+
+// expandHome replaces a leading ~ in path with the home directory of the
+// current user.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
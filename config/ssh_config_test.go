@@ -0,0 +1,39 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSSHConfig(t *testing.T) {
+	config := `
+# bastion for production
+Host bastion prod-*
+  HostName bastion.example.com
+  Port 2222
+  User deploy
+  IdentityFile /keys/deploy
+
+Host !prod-db *
+  User=fallback
+  Port 22
+`
+
+	tests := []struct {
+		host     string
+		expected sshConfigHost
+	}{
+		{"bastion", sshConfigHost{HostName: "bastion.example.com", Port: "2222", User: "deploy", IdentityFile: "/keys/deploy"}},
+		{"prod-db", sshConfigHost{HostName: "bastion.example.com", Port: "2222", User: "deploy", IdentityFile: "/keys/deploy"}},
+		{"other", sshConfigHost{Port: "22", User: "fallback"}},
+	}
+
+	for _, tt := range tests {
+		result, err := parseSSHConfig(strings.NewReader(config), tt.host)
+		require.NoError(t, err, tt.host)
+		assert.Equal(t, tt.expected, result, tt.host)
+	}
+}
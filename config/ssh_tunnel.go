@@ -0,0 +1,413 @@
+package config
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+type SSHConnConfig struct {
+	Host               string
+	Port               string
+	User               string
+	Password           string
+	PasswordFile       string
+	PasswordCommand    string
+	IdentityFile       string // IdentityFile is the path of a private key
+	IdentityPassphrase string // IdentityPassphrase decrypts a passphrase-protected IdentityFile
+	KnownHostsFile     string // KnownHostsFile defaults to ~/.ssh/known_hosts
+	// InsecureIgnoreHostKey disables host key verification. Do not use it
+	// outside of trusted networks.
+	InsecureIgnoreHostKey bool
+	// Jump lists the hosts to connect through before Host, like ProxyJump.
+	// Each is [user@]host[:port] and may be an alias from ~/.ssh/config.
+	Jump []string
+	// KeepaliveInterval is the time between keepalive requests. Zero
+	// disables them.
+	KeepaliveInterval time.Duration
+}
+
+// SSHTunnel dials connections through a chain of SSH clients. It keeps the
+// chain alive with keepalive requests and reconnects it when it was lost.
+type SSHTunnel struct {
+	config  *SSHConnConfig
+	mu      sync.Mutex
+	clients []*ssh.Client
+}
+
+func NewSSHTunnel(config *SSHConnConfig) (*SSHTunnel, error) {
+	t := &SSHTunnel{config: config}
+	if err := t.connect(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Dial opens a connection to addr from the last host of the chain. If the
+// SSH transport was lost the chain is reconnected once before giving up.
+// Errors of the last host dialing addr, e.g. a refused connection, are
+// returned as they are.
+func (t *SSHTunnel) Dial(network, addr string) (net.Conn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.clients == nil {
+		if err := t.connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := t.clients[len(t.clients)-1].Dial(network, addr)
+	if err == nil || !transportClosed(err) {
+		return conn, err
+	}
+
+	t.close()
+	if err := t.connect(); err != nil {
+		return nil, err
+	}
+	return t.clients[len(t.clients)-1].Dial(network, addr)
+}
+
+// transportClosed reports whether err of an ssh.Client means its connection
+// is gone rather than the remote host rejecting the channel.
+func transportClosed(err error) bool {
+	if _, ok := err.(*ssh.OpenChannelError); ok {
+		return false
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed)
+}
+
+// Close closes the SSH connections of the chain.
+func (t *SSHTunnel) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.close()
+}
+
+func (t *SSHTunnel) connect() error {
+	clients, err := dialSSHChain(t.config)
+	if err != nil {
+		return err
+	}
+	t.clients = clients
+
+	if t.config.KeepaliveInterval > 0 {
+		go t.keepalive(clients, t.config.KeepaliveInterval)
+	}
+	return nil
+}
+
+func (t *SSHTunnel) close() error {
+	var err error
+	for i := len(t.clients) - 1; i >= 0; i-- {
+		if closeErr := t.clients[i].Close(); err == nil {
+			err = closeErr
+		}
+	}
+	t.clients = nil
+	return err
+}
+
+// keepalive sends keepalive requests to every host of clients until one
+// fails. The chain is then closed so the next Dial reconnects.
+func (t *SSHTunnel) keepalive(clients []*ssh.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, client := range clients {
+			if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				t.mu.Lock()
+				if len(t.clients) > 0 && t.clients[0] == clients[0] {
+					t.close()
+				} else {
+					for _, c := range clients {
+						c.Close()
+					}
+				}
+				t.mu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// dialSSHChain connects to the jump hosts of config in order, each through
+// the previous one, and finally to its host. It returns the clients of all
+// hosts, the last one being connected to the host.
+func dialSSHChain(config *SSHConnConfig) ([]*ssh.Client, error) {
+	hops := make([]*SSHConnConfig, 0, len(config.Jump)+1)
+	for _, jump := range config.Jump {
+		hop, err := jumpHostConfig(config, jump)
+		if err != nil {
+			return nil, err
+		}
+		hops = append(hops, hop)
+	}
+	hops = append(hops, config)
+
+	clients := make([]*ssh.Client, 0, len(hops))
+	closeAll := func() {
+		for i := len(clients) - 1; i >= 0; i-- {
+			clients[i].Close()
+		}
+	}
+
+	for i, hop := range hops {
+		var client *ssh.Client
+		var err error
+		if i == 0 {
+			client, err = NewSSHClient(hop)
+		} else {
+			client, err = newSSHClientThrough(clients[i-1], hop)
+		}
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("ssh %s: %v", hop.Host, err)
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+// jumpHostConfig returns the config of the jump host given as
+// [user@]host[:port]. Settings not given there or in ~/.ssh/config are taken
+// from config, except for the password which only applies to its host.
+func jumpHostConfig(config *SSHConnConfig, jump string) (*SSHConnConfig, error) {
+	hop := &SSHConnConfig{
+		IdentityPassphrase:    config.IdentityPassphrase,
+		KnownHostsFile:        config.KnownHostsFile,
+		InsecureIgnoreHostKey: config.InsecureIgnoreHostKey,
+	}
+
+	if i := strings.LastIndex(jump, "@"); i >= 0 {
+		hop.User = jump[:i]
+		jump = jump[i+1:]
+	}
+
+	hop.Host = jump
+	if host, port, err := net.SplitHostPort(jump); err == nil {
+		hop.Host = host
+		hop.Port = port
+	}
+
+	if err := applySSHConfig(hop); err != nil {
+		return nil, err
+	}
+
+	if hop.User == "" {
+		hop.User = config.User
+	}
+	if hop.IdentityFile == "" {
+		hop.IdentityFile = config.IdentityFile
+	}
+	if hop.Port == "" {
+		hop.Port = "ssh"
+	}
+
+	return hop, nil
+}
+
+// newSSHClientThrough connects to the host of config through client.
+func newSSHClientThrough(client *ssh.Client, config *SSHConnConfig) (*ssh.Client, error) {
+	sshConfig, closeAgent, err := sshClientConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAgent()
+
+	addr := sshAddr(config)
+	conn, err := client.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, sshConfig)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+func NewSSHClient(config *SSHConnConfig) (*ssh.Client, error) {
+	sshConfig, closeAgent, err := sshClientConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAgent()
+
+	return ssh.Dial("tcp", sshAddr(config), sshConfig)
+}
+
+// sshAddr returns the address of the host of config with a numeric port. The
+// host key is looked up in known_hosts by this address, which lists hosts on
+// the default port without one, and a previous host of a jump chain resolves
+// it, so the default port "ssh" cannot be passed on by name.
+func sshAddr(config *SSHConnConfig) string {
+	port := config.Port
+	if port == "ssh" || port == "" {
+		port = "22"
+	}
+	return net.JoinHostPort(config.Host, port)
+}
+
+// sshClientConfig returns the client config for the host of config. The
+// returned func closes the connection to the SSH agent once the client is
+// authenticated.
+func sshClientConfig(config *SSHConnConfig) (*ssh.ClientConfig, func(), error) {
+	sshConfig := &ssh.ClientConfig{
+		User: config.User,
+	}
+
+	agentAuth, agentConn := SSHAgent()
+	closeAgent := func() {
+		if agentConn != nil {
+			agentConn.Close()
+		}
+	}
+	if agentAuth != nil {
+		sshConfig.Auth = append(sshConfig.Auth, agentAuth)
+	}
+
+	if config.IdentityFile != "" {
+		signer, err := readIdentityFile(config.IdentityFile, config.IdentityPassphrase)
+		switch {
+		case err == nil:
+			sshConfig.Auth = append(sshConfig.Auth, ssh.PublicKeys(signer))
+		case agentAuth != nil && errors.Is(err, errIdentityEncrypted):
+			// The agent may hold the key, e.g. an IdentityFile of
+			// ~/.ssh/config added with ssh-add.
+		default:
+			closeAgent()
+			return nil, nil, err
+		}
+	}
+
+	if config.Password != "" {
+		sshConfig.Auth = append(sshConfig.Auth, ssh.Password(config.Password))
+	}
+
+	hostKeyCallback, err := hostKeyCallback(config)
+	if err != nil {
+		closeAgent()
+		return nil, nil, err
+	}
+	sshConfig.HostKeyCallback = hostKeyCallback
+
+	return sshConfig, closeAgent, nil
+}
+
+// SSHAgent returns an auth method using the keys of the SSH agent at
+// SSH_AUTH_SOCK and the connection to the agent, which the caller closes
+// after authenticating. Both are nil if no agent is running.
+func SSHAgent() (ssh.AuthMethod, net.Conn) {
+	sshAgent, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+	if err != nil {
+		return nil, nil
+	}
+	return ssh.PublicKeysCallback(agent.NewClient(sshAgent).Signers), sshAgent
+}
+
+// errIdentityEncrypted is returned by readIdentityFile for a key it cannot
+// decrypt.
+var errIdentityEncrypted = errors.New("cannot decrypt identity file")
+
+// readIdentityFile parses the private key at path, decrypting it with
+// passphrase if it is protected.
+func readIdentityFile(path, passphrase string) (ssh.Signer, error) {
+	key, err := ioutil.ReadFile(expandHome(path))
+	if err != nil {
+		return nil, err
+	}
+
+	if passphrase != "" {
+		signer, err := ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+		if errors.Is(err, x509.IncorrectPasswordError) {
+			return nil, fmt.Errorf("identity file %s: %w, identity_passphrase is incorrect", path, errIdentityEncrypted)
+		}
+		return signer, err
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	if _, ok := err.(*ssh.PassphraseMissingError); ok {
+		return nil, fmt.Errorf("identity file %s: %w, set identity_passphrase or add it to the SSH agent", path, errIdentityEncrypted)
+	}
+	return signer, err
+}
+
+// hostKeyCallback verifies host keys against the known_hosts file unless
+// verification is explicitly disabled.
+func hostKeyCallback(config *SSHConnConfig) (ssh.HostKeyCallback, error) {
+	if config.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	path := config.KnownHostsFile
+	if path == "" {
+		u, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(u.HomeDir, ".ssh", "known_hosts")
+	}
+
+	callback, err := knownhosts.New(expandHome(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("known_hosts file %s not found, set insecure_ignore_host_key to skip host key verification", path)
+		}
+		return nil, err
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		if keyErr, ok := err.(*knownhosts.KeyError); ok && len(keyErr.Want) == 0 {
+			return fmt.Errorf("host key for %s is not in %s", hostname, path)
+		}
+		return err
+	}, nil
+}
+
+// applySSHConfig fills in the settings missing from config with the ones
+// the OpenSSH client config file ~/.ssh/config has for its host. The host
+// may be an alias defined there.
+func applySSHConfig(config *SSHConnConfig) error {
+	sshConfig, err := readSSHConfig(expandHome("~/.ssh/config"), config.Host)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if sshConfig.HostName != "" {
+		config.Host = sshConfig.HostName
+	}
+	if config.Port == "" {
+		config.Port = sshConfig.Port
+	}
+	if config.User == "" {
+		config.User = sshConfig.User
+	}
+	if config.IdentityFile == "" {
+		config.IdentityFile = sshConfig.IdentityFile
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestJumpHostConfig(t *testing.T) {
+	home, err := ioutil.TempDir("", "pggo")
+	require.NoError(t, err)
+	defer os.RemoveAll(home)
+
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	err = os.Mkdir(filepath.Join(home, ".ssh"), 0700)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(home, ".ssh", "config"), []byte("Host internal\n  HostName 10.0.0.5\n  User jumper\n"), 0600)
+	require.NoError(t, err)
+
+	config := &SSHConnConfig{Host: "db", User: "deploy", Password: "secret", IdentityFile: "/keys/deploy"}
+
+	hop, err := jumpHostConfig(config, "admin@bastion.example.com:2222")
+	require.NoError(t, err)
+	assert.Equal(t, "bastion.example.com", hop.Host)
+	assert.Equal(t, "2222", hop.Port)
+	assert.Equal(t, "admin", hop.User)
+	assert.Equal(t, "/keys/deploy", hop.IdentityFile)
+	assert.Equal(t, "", hop.Password)
+
+	hop, err = jumpHostConfig(config, "internal")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.5", hop.Host)
+	assert.Equal(t, "ssh", hop.Port)
+	assert.Equal(t, "jumper", hop.User)
+}
+
+func TestSSHClientConfigEncryptedIdentity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pggo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	//lint:ignore SA1019 a legacy PEM encrypted key is enough to test decryption
+	block, err := x509.EncryptPEMBlock(rand.Reader, "EC PRIVATE KEY", der, []byte("secret"), x509.PEMCipherAES256)
+	require.NoError(t, err)
+	identityFile := filepath.Join(dir, "id_ecdsa")
+	require.NoError(t, ioutil.WriteFile(identityFile, pem.EncodeToMemory(block), 0600))
+
+	_, err = readIdentityFile(identityFile, "secret")
+	assert.NoError(t, err)
+	_, err = readIdentityFile(identityFile, "wrong")
+	assert.True(t, errors.Is(err, errIdentityEncrypted))
+
+	defer os.Setenv("SSH_AUTH_SOCK", os.Getenv("SSH_AUTH_SOCK"))
+	os.Setenv("SSH_AUTH_SOCK", "")
+
+	config := &SSHConnConfig{User: "deploy", IdentityFile: identityFile, InsecureIgnoreHostKey: true}
+	_, _, err = sshClientConfig(config)
+	assert.EqualError(t, err, "identity file "+identityFile+": cannot decrypt identity file, set identity_passphrase or add it to the SSH agent")
+
+	// With an agent running the key it may hold is used instead.
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(agent.NewKeyring(), conn)
+		}
+	}()
+	os.Setenv("SSH_AUTH_SOCK", socket)
+
+	sshConfig, closeAgent, err := sshClientConfig(config)
+	require.NoError(t, err)
+	defer closeAgent()
+	assert.Len(t, sshConfig.Auth, 1)
+}
+
+func TestHostKeyCallbackDefaultPort(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pggo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	publicKey, err := ssh.NewPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	knownHosts := filepath.Join(dir, "known_hosts")
+	require.NoError(t, ioutil.WriteFile(knownHosts, []byte("bastion "+string(ssh.MarshalAuthorizedKey(publicKey))), 0600))
+
+	config := &SSHConnConfig{Host: "bastion", Port: "ssh", KnownHostsFile: knownHosts}
+	assert.Equal(t, "bastion:22", sshAddr(config))
+
+	callback, err := hostKeyCallback(config)
+	require.NoError(t, err)
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 22}
+	assert.NoError(t, callback(sshAddr(config), remote, publicKey))
+
+	config.Port = "2222"
+	assert.EqualError(t, callback(sshAddr(config), remote, publicKey), "host key for bastion:2222 is not in "+knownHosts)
+}
+
+func TestTransportClosed(t *testing.T) {
+	assert.True(t, transportClosed(io.EOF))
+	assert.True(t, transportClosed(&net.OpError{Op: "write", Net: "tcp", Err: net.ErrClosed}))
+	assert.False(t, transportClosed(&ssh.OpenChannelError{Reason: ssh.ConnectionFailed, Message: "connect failed (Connection refused)"}))
+	assert.False(t, transportClosed(errors.New("ssh: rejected: administratively prohibited")))
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgconn"
+)
+
+// defaultRootCert is the root certificate file used when sslrootcert is not
+// set.
+const defaultRootCert = "~/.postgresql/root.crt"
+
+// tlsConfigs returns the TLS configs to try in order for the sslmode of c when
+// connecting to host. A nil config means a connection without TLS. It follows
+// the sslmode behavior of libpq, which does not use TLS on unix domain
+// sockets. Settings missing from c are taken from the PG* environment
+// variables.
+func (c *Config) tlsConfigs(host string) ([]*tls.Config, error) {
+	sslmode := firstNonEmpty(c.SslMode, os.Getenv("PGSSLMODE"), "prefer")
+	if sslmode == "disable" || strings.HasPrefix(host, "/") {
+		return []*tls.Config{nil}, nil
+	}
+
+	tlsConfig := &tls.Config{}
+
+	sslrootcert := firstNonEmpty(c.SslRootCert, os.Getenv("PGSSLROOTCERT"))
+	if sslrootcert == "" && (sslmode == "require" || sslmode == "verify-ca" || sslmode == "verify-full") {
+		// Like libpq, fall back to ~/.postgresql/root.crt. Verifying the
+		// server certificate requires it, require uses it if it exists.
+		path := expandHome(defaultRootCert)
+		if _, err := os.Stat(path); err == nil {
+			sslrootcert = path
+		} else if sslmode != "require" {
+			return nil, fmt.Errorf("root certificate file %s does not exist, set sslrootcert or change sslmode to disable server certificate verification", path)
+		}
+	}
+
+	var roots *x509.CertPool
+	if sslrootcert != "" {
+		caCert, err := ioutil.ReadFile(sslrootcert)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %v", err)
+		}
+
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("unable to add CA to cert pool: %s", sslrootcert)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	switch sslmode {
+	case "allow", "prefer":
+		tlsConfig.InsecureSkipVerify = true
+	case "require":
+		// Like libpq, require verifies the certificate chain, but not the
+		// host name, when a root certificate is given.
+		tlsConfig.InsecureSkipVerify = true
+		if roots != nil {
+			tlsConfig.VerifyPeerCertificate = verifyChain(roots)
+		}
+	case "verify-ca":
+		// Verify the chain ourselves to skip the host name verification of
+		// crypto/tls.
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = verifyChain(roots)
+	case "verify-full":
+		tlsConfig.ServerName = host
+	default:
+		return nil, errors.New("sslmode is invalid")
+	}
+
+	// Send the host name for SNI unless disabled or the host is an address.
+	if c.SslSNI != "0" && net.ParseIP(host) == nil {
+		tlsConfig.ServerName = host
+	}
+
+	sslcert := firstNonEmpty(c.SslCert, os.Getenv("PGSSLCERT"))
+	sslkey := firstNonEmpty(c.SslKey, os.Getenv("PGSSLKEY"))
+	if sslcert != "" || sslkey != "" {
+		cert, err := loadClientCert(sslcert, sslkey, c.SslPassword)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	switch sslmode {
+	case "allow":
+		return []*tls.Config{nil, tlsConfig}, nil
+	case "prefer":
+		return []*tls.Config{tlsConfig, nil}, nil
+	default:
+		return []*tls.Config{tlsConfig}, nil
+	}
+}
+
+// urlTLSSettings copies the TLS settings of a connection URL or DSN to c, so
+// that TLS settings given elsewhere are combined with them.
+func (c *Config) urlTLSSettings(settings map[string]string) {
+	for key, field := range map[string]*string{
+		"sslmode":     &c.SslMode,
+		"sslrootcert": &c.SslRootCert,
+		"sslcert":     &c.SslCert,
+		"sslkey":      &c.SslKey,
+		"sslpassword": &c.SslPassword,
+		"sslsni":      &c.SslSNI,
+	} {
+		if value, ok := settings[key]; ok {
+			*field = value
+		}
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// applyTLS sets the TLS config of the connection and the fallbacks to try if
+// it fails, followed by the other hosts of a multi-host url. The server name
+// of each TLS config is the host it connects to.
+func (c *Config) applyTLS() error {
+	hosts := append([]*pgconn.FallbackConfig{{Host: c.ConnConfig.Host, Port: c.ConnConfig.Port}}, c.hosts...)
+
+	c.ConnConfig.TLSConfig = nil
+	c.ConnConfig.Fallbacks = nil
+	for i, host := range hosts {
+		tlsConfigs, err := c.tlsConfigs(host.Host)
+		if err != nil {
+			return err
+		}
+		for j, tlsConfig := range tlsConfigs {
+			if i == 0 && j == 0 {
+				c.ConnConfig.TLSConfig = tlsConfig
+				continue
+			}
+			c.ConnConfig.Fallbacks = append(c.ConnConfig.Fallbacks, &pgconn.FallbackConfig{
+				Host:      host.Host,
+				Port:      host.Port,
+				TLSConfig: tlsConfig,
+			})
+		}
+	}
+	return nil
+}
+
+// verifyChain returns a certificate verifier that checks the chain of the
+// server certificate against roots, but not its host name.
+func verifyChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(certificates [][]byte, _ [][]*x509.Certificate) error {
+		certs := make([]*x509.Certificate, len(certificates))
+		for i, asn1Data := range certificates {
+			cert, err := x509.ParseCertificate(asn1Data)
+			if err != nil {
+				return errors.New("failed to parse certificate from server: " + err.Error())
+			}
+			certs[i] = cert
+		}
+		if len(certs) == 0 {
+			return errors.New("server sent no certificate")
+		}
+
+		opts := x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(opts)
+		return err
+	}
+}
+
+// loadClientCert loads the client certificate and its key. An encrypted key
+// is decrypted with password.
+func loadClientCert(certFile, keyFile, password string) (tls.Certificate, error) {
+	if certFile == "" || keyFile == "" {
+		return tls.Certificate{}, errors.New(`both "sslcert" and "sslkey" are required`)
+	}
+
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("unable to read cert: %v", err)
+	}
+
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("unable to read key: %v", err)
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return tls.Certificate{}, fmt.Errorf("unable to decode key: %s", keyFile)
+	}
+
+	//lint:ignore SA1019 libpq supports the legacy PEM encryption as well
+	if x509.IsEncryptedPEMBlock(block) {
+		if password == "" {
+			return tls.Certificate{}, fmt.Errorf("key %s is encrypted, set sslpassword", keyFile)
+		}
+
+		//lint:ignore SA1019 libpq supports the legacy PEM encryption as well
+		der, err := x509.DecryptPEMBlock(block, []byte(password))
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("unable to decrypt key: %v", err)
+		}
+		keyPEM = pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der})
+	} else if block.Type == "ENCRYPTED PRIVATE KEY" {
+		return tls.Certificate{}, fmt.Errorf("key %s uses PKCS #8 encryption which is not supported, use a legacy PEM encrypted or unencrypted key", keyFile)
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("unable to load cert: %v", err)
+	}
+	return cert, nil
+}
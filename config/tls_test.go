@@ -0,0 +1,133 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSConfigs(t *testing.T) {
+	home, err := ioutil.TempDir("", "pggo")
+	require.NoError(t, err)
+	defer os.RemoveAll(home)
+
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+	defer os.Setenv("PGSSLROOTCERT", os.Getenv("PGSSLROOTCERT"))
+	os.Unsetenv("PGSSLROOTCERT")
+
+	// verify-ca and verify-full require a root certificate.
+	for _, sslmode := range []string{"verify-ca", "verify-full"} {
+		config := &Config{SslMode: sslmode}
+		config.ConnConfig.Host = "db.example.com"
+		_, err = config.tlsConfigs(config.ConnConfig.Host)
+		assert.EqualError(t, err, "root certificate file "+filepath.Join(home, ".postgresql", "root.crt")+" does not exist, set sslrootcert or change sslmode to disable server certificate verification", sslmode)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "root"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	require.NoError(t, os.Mkdir(filepath.Join(home, ".postgresql"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(home, ".postgresql", "root.crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+
+	tests := []struct {
+		sslmode    string
+		host       string
+		configs    int
+		firstNil   bool
+		serverName string
+		insecure   bool
+	}{
+		{"disable", "db.example.com", 1, true, "", false},
+		{"allow", "db.example.com", 2, true, "", false},
+		{"prefer", "db.example.com", 2, false, "db.example.com", true},
+		{"require", "10.0.0.1", 1, false, "", true},
+		{"verify-ca", "db.example.com", 1, false, "db.example.com", true},
+		{"verify-full", "db.example.com", 1, false, "db.example.com", false},
+	}
+
+	for _, tt := range tests {
+		config := &Config{SslMode: tt.sslmode}
+		config.ConnConfig.Host = tt.host
+
+		tlsConfigs, err := config.tlsConfigs(config.ConnConfig.Host)
+		require.NoError(t, err, tt.sslmode)
+		require.Len(t, tlsConfigs, tt.configs, tt.sslmode)
+		if tt.firstNil {
+			assert.Nil(t, tlsConfigs[0], tt.sslmode)
+			continue
+		}
+		assert.Equal(t, tt.serverName, tlsConfigs[0].ServerName, tt.sslmode)
+		assert.Equal(t, tt.insecure, tlsConfigs[0].InsecureSkipVerify, tt.sslmode)
+		if tt.sslmode == "require" || tt.sslmode == "verify-ca" || tt.sslmode == "verify-full" {
+			assert.NotNil(t, tlsConfigs[0].RootCAs, tt.sslmode)
+		}
+	}
+
+	config := &Config{SslMode: "require", SslSNI: "0"}
+	config.ConnConfig.Host = "db.example.com"
+	tlsConfigs, err := config.tlsConfigs(config.ConnConfig.Host)
+	require.NoError(t, err)
+	assert.Equal(t, "", tlsConfigs[0].ServerName)
+}
+
+func TestLoadClientCert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pggo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "migrator"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	//lint:ignore SA1019 testing the legacy PEM encryption libpq supports
+	encrypted, err := x509.EncryptPEMBlock(rand.Reader, "EC PRIVATE KEY", keyDER, []byte("secret"), x509.PEMCipherAES256)
+	require.NoError(t, err)
+
+	certPath := filepath.Join(dir, "client.crt")
+	keyPath := filepath.Join(dir, "client.key")
+	require.NoError(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(encrypted), 0600))
+
+	cert, err := loadClientCert(certPath, keyPath, "secret")
+	require.NoError(t, err)
+	assert.Len(t, cert.Certificate, 1)
+
+	_, err = loadClientCert(certPath, keyPath, "")
+	assert.EqualError(t, err, "key "+keyPath+" is encrypted, set sslpassword")
+
+	_, err = loadClientCert(certPath, keyPath, "wrong")
+	assert.Error(t, err)
+
+	_, err = loadClientCert(certPath, "", "")
+	assert.Error(t, err)
+}
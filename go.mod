@@ -1,10 +1,12 @@
 module github.com/swelf19/pggo/v2
 
-go 1.12
+go 1.16
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/jackc/pgconn v1.5.0
 	github.com/jackc/pgerrcode v0.0.0-20190803225404-afa3381909a6 // indirect
+	github.com/jackc/pgpassfile v1.0.0
 	github.com/jackc/pgx/v4 v4.6.0
 	github.com/niemeyer/pretty v0.0.0-20200227124842-a10e7caefd8e // indirect
 	github.com/pkg/errors v0.9.1
@@ -16,4 +18,5 @@ require (
 	golang.org/x/sys v0.0.0-20200413165638-669c56c373c4 // indirect
 	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543 // indirect
 	gopkg.in/check.v1 v1.0.0-20200227125254-8fa46927fb4f // indirect
+	gopkg.in/yaml.v2 v2.2.2
 )
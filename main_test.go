@@ -0,0 +1,257 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/swelf19/pggo/v2/config"
+	"github.com/swelf19/pggo/v2/migrate"
+)
+
+func TestNextMigrationName(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 30, 15, 0, time.FixedZone("CEST", 2*60*60))
+	gap := []string{"testdata/001_create_t1.sql", "testdata/003_irreversible.sql"}
+
+	name, err := nextMigrationName(gap, "add_users", false, 3, now)
+	require.NoError(t, err)
+	assert.Equal(t, "004_add_users.sql", name)
+
+	name, err = nextMigrationName(nil, "add_users", false, 4, now)
+	require.NoError(t, err)
+	assert.Equal(t, "0001_add_users.sql", name)
+
+	name, err = nextMigrationName([]string{"010_a.up.sql", "9_b.sql", "R_views.sql"}, "c", false, 3, now)
+	require.NoError(t, err)
+	assert.Equal(t, "011_c.sql", name)
+
+	name, err = nextMigrationName(gap, "add_users", true, 3, now)
+	require.NoError(t, err)
+	assert.Equal(t, "20261018073015_add_users.sql", name)
+
+	_, err = nextMigrationName([]string{"20261018073015_other.sql"}, "add_users", true, 3, now)
+	assert.EqualError(t, err, "sequence 20261018073015 is already used by 20261018073015_other.sql")
+
+	_, err = nextMigrationName([]string{"001_a.sql", "20261017000000_b.sql", "20261016000000_c.sql"}, "add_users", false, 3, now)
+	assert.EqualError(t, err, "20261017000000_b.sql has a timestamp prefix, a sequence number would sort before it, use --timestamp or new_prefix = timestamp")
+
+	// Gzipped migrations take their sequence numbers as well.
+	dir, err := ioutil.TempDir("", "pggo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err = zw.Write([]byte("create table a(id int);\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "001_a.sql.gz"), gz.Bytes(), 0644))
+
+	defer func(migrationsPath string) { cliOptions.migrationsPath = migrationsPath }(cliOptions.migrationsPath)
+	cliOptions.migrationsPath = dir
+	opts, migrationsPath, err := localMigrationSource(&config.Config{})
+	require.NoError(t, err)
+	migrations, err := migrate.FindMigrationsEx(migrationsPath, opts.MigratorFS)
+	require.NoError(t, err)
+	name, err = nextMigrationName(migrations, "b", false, 3, now)
+	require.NoError(t, err)
+	assert.Equal(t, "002_b.sql", name)
+}
+
+func TestAppendConfigFromCLIArgsPasswords(t *testing.T) {
+	c := &config.Config{PasswordCommand: "pass show db"}
+	c.ConnConfig.Password = "inline"
+	c.SSHConnConfig.Password = "inline"
+
+	cliOptions.passwordFile = "/run/secrets/db"
+	cliOptions.sshPasswordFile = "/run/secrets/ssh"
+	defer func() {
+		cliOptions.passwordFile = ""
+		cliOptions.sshPasswordFile = ""
+	}()
+
+	require.NoError(t, appendConfigFromCLIArgs(c))
+	assert.Equal(t, "", c.ConnConfig.Password)
+	assert.Equal(t, "/run/secrets/db", c.PasswordFile)
+	assert.Equal(t, "", c.PasswordCommand)
+	assert.Equal(t, "", c.SSHConnConfig.Password)
+	assert.Equal(t, "/run/secrets/ssh", c.SSHConnConfig.PasswordFile)
+
+	cliOptions.passwordFile = ""
+	cliOptions.password = "flag"
+	defer func() { cliOptions.password = "" }()
+	require.NoError(t, appendConfigFromCLIArgs(c))
+	assert.Equal(t, "flag", c.ConnConfig.Password)
+	assert.Equal(t, "", c.PasswordFile)
+}
+
+func TestStatusLine(t *testing.T) {
+	assert.Equal(t, "001_create_t1.sql", statusLine(migrate.MigrationStatus{Name: "001_create_t1.sql"}))
+	assert.Equal(t, "002_index_users_email.sql - Index users by email (author: jane; ticket: APP-123; tags: users, auth; options: no-transaction)", statusLine(migrate.MigrationStatus{
+		Name:        "002_index_users_email.sql",
+		Description: "Index users by email",
+		Author:      "jane",
+		Ticket:      "APP-123",
+		Tags:        []string{"users", "auth"},
+		Options:     []string{migrate.OptionNoTransaction},
+	}))
+}
+
+func TestPrintStatusReport(t *testing.T) {
+	appliedAt := time.Date(2026, 10, 18, 9, 30, 15, 0, time.Local)
+	report := &migrate.StatusReport{
+		Migrations: []migrate.MigrationStatus{
+			{Name: "001_create_t1.sql", State: migrate.StateApplied, Checksum: migrate.ChecksumMatch, AppliedAt: appliedAt},
+			{Name: "002_create_t2.sql", State: migrate.StateApplied, Checksum: migrate.ChecksumMismatch, AppliedAt: appliedAt},
+			{Name: "003_create_t3.sql", State: migrate.StatePending},
+			{Name: "000_removed.sql", Description: "Removed", State: migrate.StateMissing, AppliedAt: appliedAt},
+		},
+		Applied:  2,
+		Pending:  1,
+		Missing:  1,
+		Modified: 1,
+	}
+
+	var buf bytes.Buffer
+	printStatusReport(&buf, report)
+	assert.Equal(t, `status:   migration(s) pending - 1
+applied:  2
+pending migrations:    
+          003_create_t3.sql
+modified since applied:
+          002_create_t2.sql, applied 2026-10-18 09:30:15
+applied but missing:
+          000_removed.sql - Removed, applied 2026-10-18 09:30:15
+`, buf.String())
+}
+
+func TestSquash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pggo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, body := range map[string]string{
+		"001_create_t1.sql": "-- description: Create t1\ncreate table t1(m int[][] default '{{.matrix}}');\n",
+		"002_create_t2.sql": "-- depends-on: 003_create_t3.sql\ncreate table t2(id int references t3);\n",
+		"004_index_t3.sql":  "-- options: no-transaction\ncreate index concurrently t3_id_idx on t3(id);\n",
+		"005_create_t5.sql": "create table t5(id int);\n",
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0644))
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err = zw.Write([]byte("create table t3(id int primary key);\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "003_create_t3.sql.gz"), gz.Bytes(), 0644))
+
+	load := func() *migrate.Migrator {
+		migrator, err := migrate.NewMigratorEx(context.Background(), nil, "", &migrate.MigratorOptions{MigratorFS: migrate.NewGzipMigratorFS(nil)})
+		require.NoError(t, err)
+		migrator.Data = map[string]interface{}{"matrix": "{{1,2},{3,4}}"}
+		require.NoError(t, migrator.LoadMigrations(dir))
+		return migrator
+	}
+
+	_, _, err = squash(load(), dir, "005_create_t5.sql")
+	assert.EqualError(t, err, "004_index_t3.sql has the option no-transaction and cannot be squashed, squash through a migration before it")
+
+	// 002 depends on 003, so squashing through 003 leaves it out.
+	names, squashedName, err := squash(load(), dir, "003_create_t3.sql")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"001_create_t1.sql", "003_create_t3.sql"}, names)
+	assert.Equal(t, "003_squashed.sql", squashedName)
+	_, err = os.Stat(filepath.Join(dir, "003_create_t3.sql.gz"))
+	assert.True(t, os.IsNotExist(err))
+
+	migrator := load()
+	squashed := migrator.Migrations["003_squashed.sql"]
+	require.NotNil(t, squashed)
+	assert.Equal(t, []string{"001_create_t1.sql", "003_create_t3.sql"}, squashed.Replaces)
+	assert.Contains(t, squashed.UpSQL, "create table t1(m int[][] default '{{1,2},{3,4}}');")
+	order, err := migrator.MigrationOrder()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"003_squashed.sql", "002_create_t2.sql", "004_index_t3.sql", "005_create_t5.sql"}, order)
+}
+
+func TestLintDiagnostic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pggo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "001_create_users.sql")
+	body := "-- description: Create users\ncreate table users(id int);\n  lock table users;\n\n---- create above / drop below ----\n\ndrop table users;\n"
+	require.NoError(t, ioutil.WriteFile(path, []byte(body), 0644))
+
+	migrator, err := migrate.NewMigratorEx(context.Background(), nil, "", &migrate.MigratorOptions{})
+	require.NoError(t, err)
+	require.NoError(t, migrator.LoadMigrations(dir))
+	issues, err := migrator.Lint()
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+
+	diagnostic, err := lintDiagnostic(issues[0])
+	require.NoError(t, err)
+	assert.Equal(t, path+":3:3: lock-table: explicit lock table", diagnostic)
+}
+
+func TestMigrationSourceArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pggo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range map[string]string{
+		"001_create_t1.sql":      "create table t1(id int);\n{{ template \"audit.sql\" . }}\n",
+		"seeds/001_archived.sql": "insert into t1 values (1);\n",
+	} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	archive := filepath.Join(dir, "migrations.zip")
+	require.NoError(t, ioutil.WriteFile(archive, buf.Bytes(), 0644))
+
+	templates := filepath.Join(dir, "templates")
+	require.NoError(t, os.Mkdir(templates, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(templates, "audit.sql"), []byte("create table audit(id int);"), 0644))
+	seeds := filepath.Join(dir, "seeds")
+	require.NoError(t, os.Mkdir(seeds, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(seeds, "001_disk.sql"), []byte("insert into t1 values (2);\n"), 0644))
+
+	defer func(migrationsPath, seedsPath string) {
+		cliOptions.migrationsPath, cliOptions.seedsPath = migrationsPath, seedsPath
+	}(cliOptions.migrationsPath, cliOptions.seedsPath)
+	cliOptions.migrationsPath = archive
+	cliOptions.seedsPath = ""
+
+	// Template paths are read from disk, the migrations from the archive.
+	opts, path, err := migrationSource(&config.Config{TemplatePaths: []string{templates}})
+	require.NoError(t, err)
+	m, err := migrate.NewMigratorEx(context.Background(), nil, "schema_version", opts)
+	require.NoError(t, err)
+	require.NoError(t, m.LoadMigrations(path))
+	assert.Equal(t, "create table t1(id int);\ncreate table audit(id int);", m.Migrations["001_create_t1.sql"].UpSQL)
+
+	// The default seeds directory is in the archive, a --seeds path on disk.
+	seedOpts, seedsPath := seedSource(opts, path)
+	_, err = seedOpts.MigratorFS.ReadFile(filepath.Join(seedsPath, "001_archived.sql"))
+	assert.NoError(t, err)
+
+	cliOptions.seedsPath = seeds
+	seedOpts, seedsPath = seedSource(opts, path)
+	assert.Equal(t, seeds, seedsPath)
+	_, err = seedOpts.MigratorFS.ReadFile(filepath.Join(seedsPath, "001_disk.sql"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,211 @@
+package migrate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+var archiveExtensions = []string{".zip", ".tar.gz", ".tgz"}
+
+// IsArchive reports whether path names an archive NewArchiveMigratorFS can
+// read.
+func IsArchive(path string) bool {
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(strings.ToLower(path), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewArchiveMigratorFS returns a MigratorFS reading from the .zip, .tar.gz or
+// .tgz archive at path. The archive is read into memory. Paths given to the
+// Migrator are relative to the root of the archive, or to its top-level
+// directory if that is the only entry at the root, so both of these work with
+// LoadMigrations("."):
+//
+//	tar -czf migrations.tar.gz -C migrations .
+//	zip -r migrations.zip migrations
+func NewArchiveMigratorFS(path string) (MigratorFS, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fsys fs.FS
+	switch lower := strings.ToLower(path); {
+	case strings.HasSuffix(lower, ".zip"):
+		fsys, err = zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		fsys, err = tarGzFS(body)
+	default:
+		return nil, fmt.Errorf("%s: unknown archive format, expected one of %s", path, strings.Join(archiveExtensions, ", "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		fsys, err = fs.Sub(fsys, entries[0].Name())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	return NewMigratorFS(fsys), nil
+}
+
+// tarGzFS returns the regular files of a gzipped tar archive as an fs.FS. A
+// tar archive has no index to read files from directly, so they are copied to
+// an uncompressed zip archive in memory.
+func tarGzFS(body []byte) (fs.FS, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: hdr.Name, Method: zip.Store, Modified: hdr.ModTime})
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(w, tr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = zw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+}
+
+// gzipFS exposes the gzipped .sql.gz files of a MigratorFS as .sql files.
+type gzipFS struct {
+	base MigratorFS
+}
+
+// NewGzipMigratorFS returns a MigratorFS that lists and reads each name.sql.gz
+// file of base as name.sql with its content decompressed. The local file
+// system is used when base is nil. A directory holding both name.sql and
+// name.sql.gz is an error.
+func NewGzipMigratorFS(base MigratorFS) MigratorFS {
+	if base == nil {
+		base = defaultMigratorFS{}
+	}
+	return gzipFS{base: base}
+}
+
+// gzipFileInfo renames the os.FileInfo of a .sql.gz file.
+type gzipFileInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (fi gzipFileInfo) Name() string {
+	return fi.name
+}
+
+func (f gzipFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	fileInfos, err := f.base.ReadDir(dirname)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(fileInfos))
+	for _, fi := range fileInfos {
+		names[fi.Name()] = true
+	}
+
+	for i, fi := range fileInfos {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), ".sql.gz") {
+			continue
+		}
+		name := strings.TrimSuffix(fi.Name(), ".gz")
+		if names[name] {
+			return nil, fmt.Errorf("%s: both %s and %s exist", dirname, name, fi.Name())
+		}
+		fileInfos[i] = gzipFileInfo{FileInfo: fi, name: name}
+	}
+	sort.Slice(fileInfos, func(i, j int) bool { return fileInfos[i].Name() < fileInfos[j].Name() })
+
+	return fileInfos, nil
+}
+
+func (f gzipFS) ReadFile(filename string) ([]byte, error) {
+	body, err := f.base.ReadFile(filename)
+	if err == nil || !strings.HasSuffix(filename, ".sql") || !errors.Is(err, fs.ErrNotExist) {
+		return body, err
+	}
+
+	compressed, gzErr := f.base.ReadFile(filename + ".gz")
+	if gzErr != nil {
+		if errors.Is(gzErr, fs.ErrNotExist) {
+			return nil, err
+		}
+		return nil, gzErr
+	}
+
+	gz, gzErr := gzip.NewReader(bytes.NewReader(compressed))
+	if gzErr != nil {
+		return nil, fmt.Errorf("%s.gz: %v", filename, gzErr)
+	}
+	defer gz.Close()
+
+	body, gzErr = ioutil.ReadAll(gz)
+	if gzErr != nil {
+		return nil, fmt.Errorf("%s.gz: %v", filename, gzErr)
+	}
+	return body, nil
+}
+
+func (f gzipFS) Glob(pattern string) ([]string, error) {
+	matches, err := f.base.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	gzMatches, err := f.base.Glob(pattern + ".gz")
+	if err != nil {
+		return nil, err
+	}
+	for _, match := range gzMatches {
+		if strings.HasSuffix(match, ".sql.gz") {
+			matches = append(matches, strings.TrimSuffix(match, ".gz"))
+		}
+	}
+	sort.Strings(matches)
+
+	return matches, nil
+}
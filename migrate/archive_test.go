@@ -0,0 +1,142 @@
+package migrate_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/swelf19/pggo/v2/migrate"
+)
+
+var archiveFiles = []struct {
+	name string
+	body string
+}{
+	{"001_create_t1.sql", "create table t1(id serial primary key);\n---- create above / drop below ----\ndrop table t1;\n"},
+	{"002_create_t2.up.sql", "create table t2(id serial primary key);\n{{ template \"shared/v2.sql\" . }}\n"},
+	{"002_create_t2.down.sql", "drop table t2;\n"},
+	{"shared/v2.sql", "create view v2 as select * from t2;"},
+	{"seeds/001_t1.sql", "insert into t1 default values;\n"},
+}
+
+func gzipBytes(t *testing.T, body []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func writeZip(t *testing.T, path, prefix string) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range archiveFiles {
+		w, err := zw.Create(prefix + f.name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(f.body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, ioutil.WriteFile(path, buf.Bytes(), 0644))
+}
+
+func writeTarGz(t *testing.T, path, prefix string) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: prefix, Typeflag: tar.TypeDir, Mode: 0755}))
+	for _, f := range archiveFiles {
+		err := tw.WriteHeader(&tar.Header{Name: prefix + f.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(f.body))})
+		require.NoError(t, err)
+		_, err = tw.Write([]byte(f.body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, ioutil.WriteFile(path, gzipBytes(t, buf.Bytes()), 0644))
+}
+
+func TestNewArchiveMigratorFS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pggo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeZip(t, filepath.Join(dir, "flat.zip"), "")
+	writeZip(t, filepath.Join(dir, "nested.zip"), "migrations/")
+	writeTarGz(t, filepath.Join(dir, "flat.tar.gz"), "./")
+	writeTarGz(t, filepath.Join(dir, "nested.tgz"), "migrations/")
+
+	for _, name := range []string{"flat.zip", "nested.zip", "flat.tar.gz", "nested.tgz"} {
+		path := filepath.Join(dir, name)
+		require.True(t, migrate.IsArchive(path), name)
+
+		fs, err := migrate.NewArchiveMigratorFS(path)
+		require.NoError(t, err, name)
+		opts := &migrate.MigratorOptions{MigratorFS: fs}
+
+		m, err := migrate.NewMigratorEx(context.Background(), nil, "schema_version", opts)
+		require.NoError(t, err)
+		err = m.LoadMigrations(".")
+		require.NoError(t, err, name)
+		require.Len(t, m.Migrations, 2, name)
+		assert.Equal(t, "drop table t1;", m.Migrations["001_create_t1.sql"].DownSQL, name)
+		assert.Equal(t, "create table t2(id serial primary key);\ncreate view v2 as select * from t2;", m.Migrations["002_create_t2.sql"].UpSQL, name)
+
+		seed, err := fs.ReadFile(filepath.Join("seeds", "001_t1.sql"))
+		require.NoError(t, err, name)
+		assert.Equal(t, "insert into t1 default values;\n", string(seed), name)
+	}
+
+	assert.False(t, migrate.IsArchive(dir))
+	_, err = migrate.NewArchiveMigratorFS(filepath.Join(dir, "missing.zip"))
+	assert.Error(t, err)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "broken.tar.gz"), []byte("not gzip"), 0644)
+	require.NoError(t, err)
+	_, err = migrate.NewArchiveMigratorFS(filepath.Join(dir, "broken.tar.gz"))
+	assert.Error(t, err)
+}
+
+func TestNewGzipMigratorFS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pggo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	body := []byte("create table t1(id serial primary key);\n---- create above / drop below ----\ndrop table t1;\n")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "001_create_t1.sql.gz"), gzipBytes(t, body), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "002_create_t2.sql"), []byte("create table t2(id serial primary key);\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "R_views.sql.gz"), gzipBytes(t, []byte("create or replace view v1 as select 1;\n")), 0644))
+
+	opts := &migrate.MigratorOptions{MigratorFS: migrate.NewGzipMigratorFS(nil)}
+	m, err := migrate.NewMigratorEx(context.Background(), nil, "schema_version", opts)
+	require.NoError(t, err)
+	err = m.LoadMigrations(dir)
+	require.NoError(t, err)
+	require.Len(t, m.Migrations, 2)
+	assert.Equal(t, "create table t1(id serial primary key);", m.Migrations["001_create_t1.sql"].UpSQL)
+	assert.Equal(t, "drop table t1;", m.Migrations["001_create_t1.sql"].DownSQL)
+	require.Len(t, m.Repeatables, 1)
+	assert.Equal(t, "create or replace view v1 as select 1;", m.Repeatables["R_views.sql"].UpSQL)
+
+	matches, err := opts.MigratorFS.Glob(filepath.Join(dir, "*.sql"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "001_create_t1.sql"),
+		filepath.Join(dir, "002_create_t2.sql"),
+		filepath.Join(dir, "R_views.sql"),
+	}, matches)
+
+	_, err = opts.MigratorFS.ReadFile(filepath.Join(dir, "003_missing.sql"))
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "001_create_t1.sql"), body, 0644))
+	err = m.LoadMigrations(dir)
+	assert.EqualError(t, err, dir+": both 001_create_t1.sql and 001_create_t1.sql.gz exist")
+}
@@ -0,0 +1,177 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Catalog is a snapshot of the schema objects of a database. Objects maps a
+// description of each object, e.g. "index public.users_pkey", to its
+// definition.
+type Catalog struct {
+	Objects map[string]string
+}
+
+// CatalogChange is an object that differs between two catalogs. Before is
+// empty for an added object and After for a removed one.
+type CatalogChange struct {
+	Object string
+	Before string
+	After  string
+}
+
+func (c CatalogChange) String() string {
+	switch {
+	case c.Before == "":
+		return fmt.Sprintf("+ %s: %s", c.Object, c.After)
+	case c.After == "":
+		return fmt.Sprintf("- %s: %s", c.Object, c.Before)
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", c.Object, c.Before, c.After)
+}
+
+// userSchemas filters n.nspname to schemas not owned by PostgreSQL itself.
+const userSchemas = `n.nspname not in ('pg_catalog', 'information_schema') and n.nspname not like 'pg\_toast%' and n.nspname not like 'pg\_temp\_%'`
+
+// catalogQueries select the object and definition of each kind of schema
+// object.
+var catalogQueries = []string{
+	`select 'schema ' || n.nspname, pg_get_userbyid(n.nspowner)
+	from pg_namespace n
+	where ` + userSchemas,
+
+	`select case c.relkind
+		when 'r' then 'table '
+		when 'p' then 'table '
+		when 'v' then 'view '
+		when 'm' then 'materialized view '
+		when 'S' then 'sequence '
+		when 'f' then 'foreign table '
+	end || quote_ident(n.nspname) || '.' || quote_ident(c.relname),
+	case when c.relkind in ('v', 'm') then pg_get_viewdef(c.oid) else '' end
+	from pg_class c
+	join pg_namespace n on n.oid = c.relnamespace
+	where c.relkind in ('r', 'p', 'v', 'm', 'S', 'f') and ` + userSchemas,
+
+	`select 'column ' || quote_ident(n.nspname) || '.' || quote_ident(c.relname) || '.' || quote_ident(a.attname),
+	format_type(a.atttypid, a.atttypmod)
+		|| case when a.attnotnull then ' not null' else '' end
+		|| coalesce(' default ' || pg_get_expr(d.adbin, d.adrelid), '')
+	from pg_attribute a
+	join pg_class c on c.oid = a.attrelid
+	join pg_namespace n on n.oid = c.relnamespace
+	left join pg_attrdef d on d.adrelid = a.attrelid and d.adnum = a.attnum
+	where a.attnum > 0 and not a.attisdropped and c.relkind in ('r', 'p', 'v', 'm', 'f') and ` + userSchemas,
+
+	// Indexes of primary key, unique and exclusion constraints are covered by
+	// the constraint.
+	`select 'index ' || quote_ident(n.nspname) || '.' || quote_ident(c.relname), pg_get_indexdef(c.oid)
+	from pg_index i
+	join pg_class c on c.oid = i.indexrelid
+	join pg_namespace n on n.oid = c.relnamespace
+	where not exists (
+		select 1 from pg_constraint con
+		where con.conindid = i.indexrelid and con.conrelid = i.indrelid and con.contype in ('p', 'u', 'x')
+	) and ` + userSchemas,
+
+	`select 'constraint ' || quote_ident(n.nspname) || '.' || quote_ident(c.relname) || '.' || quote_ident(con.conname), pg_get_constraintdef(con.oid)
+	from pg_constraint con
+	join pg_class c on c.oid = con.conrelid
+	join pg_namespace n on n.oid = c.relnamespace
+	where ` + userSchemas,
+
+	`select 'function ' || quote_ident(n.nspname) || '.' || quote_ident(p.proname) || '(' || pg_get_function_identity_arguments(p.oid) || ')',
+	pg_get_function_result(p.oid) || ' ' || md5(p.prosrc)
+	from pg_proc p
+	join pg_namespace n on n.oid = p.pronamespace
+	where ` + userSchemas,
+
+	`select 'trigger ' || quote_ident(n.nspname) || '.' || quote_ident(c.relname) || '.' || quote_ident(t.tgname), pg_get_triggerdef(t.oid)
+	from pg_trigger t
+	join pg_class c on c.oid = t.tgrelid
+	join pg_namespace n on n.oid = c.relnamespace
+	where not t.tgisinternal and ` + userSchemas,
+
+	`select 'grants schema ' || quote_ident(n.nspname),
+	(select string_agg(a::text, ', ' order by a::text) from unnest(n.nspacl) a)
+	from pg_namespace n
+	where n.nspacl is not null and ` + userSchemas,
+
+	`select 'grants ' || case c.relkind when 'S' then 'sequence ' else 'table ' end || quote_ident(n.nspname) || '.' || quote_ident(c.relname),
+	(select string_agg(a::text, ', ' order by a::text) from unnest(c.relacl) a)
+	from pg_class c
+	join pg_namespace n on n.oid = c.relnamespace
+	where c.relacl is not null and c.relkind in ('r', 'p', 'v', 'm', 'S', 'f') and ` + userSchemas,
+
+	`select 'grants function ' || quote_ident(n.nspname) || '.' || quote_ident(p.proname) || '(' || pg_get_function_identity_arguments(p.oid) || ')',
+	(select string_agg(a::text, ', ' order by a::text) from unnest(p.proacl) a)
+	from pg_proc p
+	join pg_namespace n on n.oid = p.pronamespace
+	where p.proacl is not null and ` + userSchemas,
+
+	`select 'type ' || quote_ident(n.nspname) || '.' || quote_ident(t.typname),
+	coalesce((select string_agg(quote_literal(e.enumlabel), ', ' order by e.enumsortorder) from pg_enum e where e.enumtypid = t.oid), '')
+	from pg_type t
+	join pg_namespace n on n.oid = t.typnamespace
+	where t.typtype in ('e', 'd') and ` + userSchemas,
+}
+
+// SnapshotCatalog reads the schema objects of the database conn is connected
+// to.
+func SnapshotCatalog(ctx context.Context, conn DBConnection) (*Catalog, error) {
+	catalog := &Catalog{Objects: make(map[string]string)}
+	for _, query := range catalogQueries {
+		rows, err := conn.Query(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var object, definition string
+			err = rows.Scan(&object, &definition)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			catalog.Objects[object] = definition
+		}
+		rows.Close()
+		if rows.Err() != nil {
+			return nil, rows.Err()
+		}
+	}
+
+	return catalog, nil
+}
+
+// Diff returns the objects added, removed or changed in other compared to c,
+// sorted by object.
+func (c *Catalog) Diff(other *Catalog) []CatalogChange {
+	var changes []CatalogChange
+	for object, before := range c.Objects {
+		after, ok := other.Objects[object]
+		if !ok {
+			changes = append(changes, CatalogChange{Object: object, Before: orNone(before)})
+		} else if before != after {
+			changes = append(changes, CatalogChange{Object: object, Before: orNone(before), After: orNone(after)})
+		}
+	}
+	for object, after := range other.Objects {
+		if _, ok := c.Objects[object]; !ok {
+			changes = append(changes, CatalogChange{Object: object, After: orNone(after)})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Object < changes[j].Object })
+	return changes
+}
+
+// orNone keeps an object without a definition distinguishable from a missing
+// one in a CatalogChange.
+func orNone(definition string) string {
+	if definition == "" {
+		return "(exists)"
+	}
+	return definition
+}
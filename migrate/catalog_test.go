@@ -0,0 +1,33 @@
+package migrate_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/swelf19/pggo/v2/migrate"
+)
+
+func TestCatalogDiff(t *testing.T) {
+	before := &migrate.Catalog{Objects: map[string]string{
+		"table public.t1":     "",
+		"column public.t1.id": "integer not null",
+		"index public.t1_idx": "CREATE INDEX t1_idx ON public.t1 USING btree (id)",
+	}}
+	after := &migrate.Catalog{Objects: map[string]string{
+		"table public.t1":     "",
+		"column public.t1.id": "bigint not null",
+		"table public.t2":     "",
+	}}
+
+	changes := before.Diff(after)
+	assert.Equal(t, []migrate.CatalogChange{
+		{Object: "column public.t1.id", Before: "integer not null", After: "bigint not null"},
+		{Object: "index public.t1_idx", Before: "CREATE INDEX t1_idx ON public.t1 USING btree (id)"},
+		{Object: "table public.t2", After: "(exists)"},
+	}, changes)
+	assert.Equal(t, "~ column public.t1.id: integer not null -> bigint not null", changes[0].String())
+	assert.Equal(t, "- index public.t1_idx: CREATE INDEX t1_idx ON public.t1 USING btree (id)", changes[1].String())
+	assert.Equal(t, "+ table public.t2: (exists)", changes[2].String())
+
+	assert.Empty(t, before.Diff(before))
+}
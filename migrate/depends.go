@@ -0,0 +1,145 @@
+package migrate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type MissingDependencyError struct {
+	MigrationName string
+	Dependency    string
+}
+
+func (e MissingDependencyError) Error() string {
+	return fmt.Sprintf(`Migration "%s" depends on missing migration "%s"`, e.MigrationName, e.Dependency)
+}
+
+type DependencyCycleError struct {
+	MigrationNames []string
+}
+
+func (e DependencyCycleError) Error() string {
+	return fmt.Sprintf("Migration dependency cycle involving: %s", strings.Join(e.MigrationNames, ", "))
+}
+
+type DependentMigrationError struct {
+	MigrationName string
+	Dependents    []string
+}
+
+func (e DependentMigrationError) Error() string {
+	return fmt.Sprintf(`Migration "%s" cannot be rolled back while applied migrations depend on it: %s`, e.MigrationName, strings.Join(e.Dependents, ", "))
+}
+
+// dependencies returns the names of the migrations migration depends on. A
+// dependency on a squashed migration is a dependency on the migration that
+// replaces it.
+func (m *Migrator) dependencies(migration *Migration, replacedBy map[string]string) ([]string, error) {
+	deps := make([]string, 0, len(migration.DependsOn))
+	for _, dep := range migration.DependsOn {
+		if squashed, ok := replacedBy[dep]; ok {
+			dep = squashed
+		}
+		if _, ok := m.Migrations[dep]; !ok {
+			return nil, MissingDependencyError{MigrationName: migration.Name, Dependency: dep}
+		}
+		if dep != migration.Name && Position(deps, dep) < 0 {
+			deps = append(deps, dep)
+		}
+	}
+	return deps, nil
+}
+
+// MigrationOrder returns the names of all migrations in the order they are
+// applied. Migrations are sorted topologically by their dependencies and by
+// name where the dependencies leave a choice, so without any dependencies the
+// order is the name order.
+func (m *Migrator) MigrationOrder() ([]string, error) {
+	replacedBy := make(map[string]string)
+	for _, migration := range m.Migrations {
+		for _, name := range migration.Replaces {
+			replacedBy[name] = migration.Name
+		}
+	}
+
+	pending := make(map[string]int, len(m.Migrations))
+	dependents := make(map[string][]string)
+	for name, migration := range m.Migrations {
+		deps, err := m.dependencies(migration, replacedBy)
+		if err != nil {
+			return nil, err
+		}
+		pending[name] = len(deps)
+		for _, dep := range deps {
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	var ready []string
+	for name, count := range pending {
+		if count == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	order := make([]string, 0, len(m.Migrations))
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		name := ready[0]
+		ready = ready[1:]
+		order = append(order, name)
+		delete(pending, name)
+
+		for _, dependent := range dependents[name] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(pending) > 0 {
+		names := make([]string, 0, len(pending))
+		for name := range pending {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, DependencyCycleError{MigrationNames: names}
+	}
+
+	return order, nil
+}
+
+// checkRollback returns a DependentMigrationError if a migration in rollback
+// is depended on by an applied migration that stays applied.
+func (m *Migrator) checkRollback(applied, rollback []string) error {
+	replacedBy := make(map[string]string)
+	for _, migration := range m.Migrations {
+		for _, name := range migration.Replaces {
+			replacedBy[name] = migration.Name
+		}
+	}
+
+	for _, name := range rollback {
+		var blocking []string
+		for _, other := range applied {
+			migration, ok := m.Migrations[other]
+			if !ok || Position(rollback, other) >= 0 {
+				continue
+			}
+			deps, err := m.dependencies(migration, replacedBy)
+			if err != nil {
+				return err
+			}
+			if Position(deps, name) >= 0 {
+				blocking = append(blocking, other)
+			}
+		}
+		if len(blocking) > 0 {
+			return DependentMigrationError{MigrationName: name, Dependents: blocking}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package migrate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// objectKinds orders the kinds of catalog objects so that an object is created
+// after the objects it needs. Drops run in the reverse order.
+var objectKinds = []string{
+	"schema",
+	"type",
+	"sequence",
+	"table",
+	"foreign table",
+	"column",
+	"constraint",
+	"index",
+	"view",
+	"materialized view",
+	"function",
+	"trigger",
+	"grants",
+}
+
+// splitObject splits a catalog object such as "column public.t.c" into its
+// kind and name.
+func splitObject(object string) (kind, name string) {
+	for i := len(objectKinds) - 1; i >= 0; i-- {
+		if strings.HasPrefix(object, objectKinds[i]+" ") {
+			return objectKinds[i], strings.TrimPrefix(object, objectKinds[i]+" ")
+		}
+	}
+	return "", object
+}
+
+func kindRank(kind string) int {
+	for i, k := range objectKinds {
+		if k == kind {
+			return i
+		}
+	}
+	return len(objectKinds)
+}
+
+// definition returns the definition of a catalog object, empty for objects
+// that only have a name.
+func definition(s string) string {
+	if s == orNone("") {
+		return ""
+	}
+	return s
+}
+
+// splitLast splits a name such as "public.t.c" on its last dot.
+func splitLast(name string) (string, string) {
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 {
+		return "", name
+	}
+	return name[:i], name[i+1:]
+}
+
+// changeSQL returns the SQL that turns Before into After for one object. What
+// cannot be derived from the catalog is left as a TODO comment.
+func changeSQL(c CatalogChange) []string {
+	kind, name := splitObject(c.Object)
+	after := definition(c.After)
+	todo := fmt.Sprintf("-- TODO: %s", c)
+
+	switch kind {
+	case "schema", "sequence", "table":
+		switch {
+		case c.Before == "" && kind == "table":
+			// The columns are added by their own changes.
+			return []string{fmt.Sprintf("create table %s ();", name)}
+		case c.Before == "":
+			return []string{fmt.Sprintf("create %s %s;", kind, name)}
+		case c.After == "":
+			return []string{fmt.Sprintf("drop %s %s;", kind, name)}
+		}
+	case "view", "materialized view":
+		create := fmt.Sprintf("create %s %s as\n%s", kind, name, strings.TrimSuffix(strings.TrimSpace(after), ";")+";")
+		switch {
+		case c.Before == "":
+			return []string{create}
+		case c.After == "":
+			return []string{fmt.Sprintf("drop %s %s;", kind, name)}
+		default:
+			return []string{fmt.Sprintf("drop %s %s;", kind, name), create}
+		}
+	case "column":
+		table, column := splitLast(name)
+		switch {
+		case c.Before == "":
+			return []string{fmt.Sprintf("alter table %s add column %s %s;", table, column, after)}
+		case c.After == "":
+			return []string{fmt.Sprintf("alter table %s drop column %s;", table, column)}
+		}
+	case "index":
+		switch {
+		case c.Before == "":
+			return []string{after + ";"}
+		case c.After == "":
+			return []string{fmt.Sprintf("drop index %s;", name)}
+		default:
+			return []string{fmt.Sprintf("drop index %s;", name), after + ";"}
+		}
+	case "constraint":
+		table, constraint := splitLast(name)
+		drop := fmt.Sprintf("alter table %s drop constraint %s;", table, constraint)
+		add := fmt.Sprintf("alter table %s add constraint %s %s;", table, constraint, after)
+		switch {
+		case c.Before == "":
+			return []string{add}
+		case c.After == "":
+			return []string{drop}
+		default:
+			return []string{drop, add}
+		}
+	case "trigger":
+		table, trigger := splitLast(name)
+		drop := fmt.Sprintf("drop trigger %s on %s;", trigger, table)
+		switch {
+		case c.Before == "":
+			return []string{after + ";"}
+		case c.After == "":
+			return []string{drop}
+		default:
+			return []string{drop, after + ";"}
+		}
+	case "function":
+		if c.After == "" {
+			return []string{fmt.Sprintf("drop function %s;", name)}
+		}
+	case "type":
+		switch {
+		case c.Before == "" && after != "":
+			return []string{fmt.Sprintf("create type %s as enum (%s);", name, after)}
+		case c.After == "":
+			return []string{fmt.Sprintf("drop type %s;", name)}
+		}
+	}
+
+	return []string{todo}
+}
+
+// DraftMigration returns a migration in pggo's format whose up section turns a
+// database with the from catalog of changes into one with the to catalog, and
+// whose down section reverses it. Changes that cannot be derived from the
+// catalog, such as function bodies, grants and column type changes, are left
+// as TODO comments. The draft must be reviewed before use.
+func DraftMigration(changes []CatalogChange) string {
+	down := make([]CatalogChange, len(changes))
+	for i, c := range changes {
+		down[i] = CatalogChange{Object: c.Object, Before: c.After, After: c.Before}
+	}
+
+	var b strings.Builder
+	b.WriteString("-- description: Draft generated by pggo diff, review before use\n")
+	writeChangesSQL(&b, changes)
+	b.WriteString("\n---- create above / drop below ----\n")
+	writeChangesSQL(&b, down)
+	return b.String()
+}
+
+// writeChangesSQL writes the SQL of changes to b. Drops run first in reverse
+// dependency order, followed by creates and changes in dependency order.
+func writeChangesSQL(b *strings.Builder, changes []CatalogChange) {
+	var drops, creates []CatalogChange
+	for _, c := range changes {
+		if c.After == "" {
+			drops = append(drops, c)
+		} else {
+			creates = append(creates, c)
+		}
+	}
+
+	sort.SliceStable(drops, func(i, j int) bool {
+		ki, _ := splitObject(drops[i].Object)
+		kj, _ := splitObject(drops[j].Object)
+		return kindRank(ki) > kindRank(kj)
+	})
+	sort.SliceStable(creates, func(i, j int) bool {
+		ki, _ := splitObject(creates[i].Object)
+		kj, _ := splitObject(creates[j].Object)
+		return kindRank(ki) < kindRank(kj)
+	})
+
+	for _, group := range [][]CatalogChange{drops, creates} {
+		for _, c := range group {
+			for _, sql := range changeSQL(c) {
+				b.WriteString("\n")
+				b.WriteString(sql)
+				b.WriteString("\n")
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package migrate_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/swelf19/pggo/v2/migrate"
+)
+
+func TestDraftMigration(t *testing.T) {
+	from := &migrate.Catalog{Objects: map[string]string{
+		"table public.t1":         "(exists)",
+		"column public.t1.id":     "integer not null",
+		"column public.t1.legacy": "text",
+		"index public.t1_legacy":  "CREATE INDEX t1_legacy ON public.t1 USING btree (legacy)",
+	}}
+	to := &migrate.Catalog{Objects: map[string]string{
+		"table public.t1":                  "(exists)",
+		"column public.t1.id":              "integer not null",
+		"table public.t2":                  "(exists)",
+		"column public.t2.id":              "integer not null",
+		"constraint public.t2.t2_pkey":     "PRIMARY KEY (id)",
+		"grants table public.t2":           "app=r/postgres",
+		"function public.f(integer)":       "integer 1a2b",
+		"type public.mood":                 "'happy', 'sad'",
+		"view public.v2":                   " SELECT t2.id\n   FROM t2;",
+		"constraint public.t1.t1_id_check": "CHECK ((id > 0))",
+	}}
+
+	assert.Equal(t, `-- description: Draft generated by pggo diff, review before use
+
+drop index public.t1_legacy;
+
+alter table public.t1 drop column legacy;
+
+create type public.mood as enum ('happy', 'sad');
+
+create table public.t2 ();
+
+alter table public.t2 add column id integer not null;
+
+alter table public.t1 add constraint t1_id_check CHECK ((id > 0));
+
+alter table public.t2 add constraint t2_pkey PRIMARY KEY (id);
+
+create view public.v2 as
+SELECT t2.id
+   FROM t2;
+
+-- TODO: + function public.f(integer): integer 1a2b
+
+-- TODO: + grants table public.t2: app=r/postgres
+
+---- create above / drop below ----
+
+-- TODO: - grants table public.t2: app=r/postgres
+
+drop function public.f(integer);
+
+drop view public.v2;
+
+alter table public.t1 drop constraint t1_id_check;
+
+alter table public.t2 drop constraint t2_pkey;
+
+alter table public.t2 drop column id;
+
+drop table public.t2;
+
+drop type public.mood;
+
+alter table public.t1 add column legacy text;
+
+CREATE INDEX t1_legacy ON public.t1 USING btree (legacy);
+`, migrate.DraftMigration(from.Diff(to)))
+}
@@ -0,0 +1,140 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// TemplateFuncs returns the functions available in migration, shared and
+// seed templates. pggo also makes them available in config files.
+//
+//	quoteIdent   quotes its arguments as one possibly qualified identifier
+//	quoteLiteral quotes a value as a string literal, nil becomes NULL
+//	default      returns the given value, or the default if it is empty
+//	required     fails the template if the value is empty
+//	env          returns the environment variable
+//	join         joins the items of a list with a separator
+//	split        splits a string by a separator
+//	now          returns the current time
+//	date         formats a time with a Go layout
+//	dateAdd      adds a duration such as "-24h" to a time
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"quoteIdent":   QuoteIdent,
+		"quoteLiteral": QuoteLiteral,
+		"default":      defaultValue,
+		"required":     required,
+		"env":          os.Getenv,
+		"join":         join,
+		"split":        split,
+		"now":          time.Now,
+		"date":         date,
+		"dateAdd":      dateAdd,
+	}
+}
+
+// QuoteIdent quotes parts as an identifier qualified by the parts before the
+// last one, e.g. QuoteIdent("public", "users") returns "public"."users".
+func QuoteIdent(parts ...interface{}) (string, error) {
+	if len(parts) == 0 {
+		return "", errors.New("quoteIdent requires an identifier")
+	}
+
+	ident := make(pgx.Identifier, len(parts))
+	for i, part := range parts {
+		if isEmpty(part) {
+			return "", errors.New("quoteIdent requires a non-empty identifier")
+		}
+		ident[i] = fmt.Sprint(part)
+	}
+	return ident.Sanitize(), nil
+}
+
+// QuoteLiteral quotes value as a string literal like PostgreSQL's
+// quote_nullable. A value containing backslashes is quoted as an escape
+// string.
+func QuoteLiteral(value interface{}) string {
+	if value == nil {
+		return "NULL"
+	}
+
+	s := strings.Replace(fmt.Sprint(value), "'", "''", -1)
+	if strings.Contains(s, `\`) {
+		return `E'` + strings.Replace(s, `\`, `\\`, -1) + `'`
+	}
+	return `'` + s + `'`
+}
+
+// defaultValue returns the last of given, which is the piped value when
+// called as {{.value | default "x"}}, or def if it is empty.
+func defaultValue(def interface{}, given ...interface{}) interface{} {
+	if len(given) == 0 || isEmpty(given[len(given)-1]) {
+		return def
+	}
+	return given[len(given)-1]
+}
+
+// required returns value or fails with message if it is empty.
+func required(message string, value interface{}) (interface{}, error) {
+	if isEmpty(value) {
+		return nil, errors.New(message)
+	}
+	return value, nil
+}
+
+func join(sep string, list interface{}) (string, error) {
+	v := reflect.ValueOf(list)
+	if !v.IsValid() {
+		return "", nil
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return fmt.Sprint(list), nil
+	}
+
+	items := make([]string, v.Len())
+	for i := range items {
+		items[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(items, sep), nil
+}
+
+func split(sep, s string) []string {
+	return strings.Split(s, sep)
+}
+
+func date(layout string, t time.Time) string {
+	return t.Format(layout)
+}
+
+func dateAdd(duration string, t time.Time) (time.Time, error) {
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.Add(d), nil
+}
+
+// isEmpty reports whether value is nil or the zero value of its type, or an
+// empty collection.
+func isEmpty(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return true
+	}
+
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	default:
+		return reflect.DeepEqual(value, reflect.Zero(v.Type()).Interface())
+	}
+}
@@ -0,0 +1,62 @@
+package migrate_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/swelf19/pggo/v2/migrate"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	os.Setenv("PGGO_TEST_FUNCS", "from env")
+	defer os.Unsetenv("PGGO_TEST_FUNCS")
+
+	data := map[string]interface{}{
+		"role":   `app"user`,
+		"schema": "public",
+		"name":   "O'Brien",
+		"path":   `C:\data`,
+		"roles":  []interface{}{"reader", "writer"},
+		"csv":    "a,b",
+		"empty":  "",
+	}
+
+	tests := []struct {
+		tmpl     string
+		expected string
+	}{
+		{`{{quoteIdent .role}}`, `"app""user"`},
+		{`{{quoteIdent .schema "users"}}`, `"public"."users"`},
+		{`{{quoteLiteral .name}}`, `'O''Brien'`},
+		{`{{quoteLiteral .path}}`, `E'C:\\data'`},
+		{`{{quoteLiteral .missing}}`, `NULL`},
+		{`{{.missing | default "fallback"}}`, `fallback`},
+		{`{{.empty | default "fallback"}}`, `fallback`},
+		{`{{default "fallback" .schema}}`, `public`},
+		{`{{required "schema is required" .schema}}`, `public`},
+		{`{{env "PGGO_TEST_FUNCS"}}`, `from env`},
+		{`{{join ", " .roles}}`, `reader, writer`},
+		{`{{range split "," .csv}}[{{.}}]{{end}}`, `[a][b]`},
+		{`{{now | date "2006" | len}}`, `4`},
+	}
+
+	for _, tt := range tests {
+		tmpl, err := template.New("test").Funcs(migrate.TemplateFuncs()).Parse(tt.tmpl)
+		require.NoError(t, err, tt.tmpl)
+
+		var buf strings.Builder
+		err = tmpl.Execute(&buf, data)
+		require.NoError(t, err, tt.tmpl)
+		assert.Equal(t, tt.expected, buf.String(), tt.tmpl)
+	}
+
+	tmpl, err := template.New("test").Funcs(migrate.TemplateFuncs()).Parse(`{{required "app_user is required" .app_user}}`)
+	require.NoError(t, err)
+	err = tmpl.Execute(&strings.Builder{}, data)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "app_user is required")
+}
@@ -0,0 +1,102 @@
+package migrate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// headerPattern matches a "-- key: value" line of the comment block at the top
+// of a migration.
+var headerPattern = regexp.MustCompile(`\A--\s*([a-z][a-z-]*)\s*:\s*(.*)\z`)
+
+// Migration options that can be set in the header.
+const (
+	// OptionNoTransaction runs the migration outside of a transaction, e.g. for
+	// create index concurrently.
+	OptionNoTransaction = "no-transaction"
+)
+
+var knownOptions = map[string]bool{
+	OptionNoTransaction: true,
+}
+
+// parseHeader fills the metadata of migration from the header of sql, the
+// comment block at its top:
+//
+//	-- description: Create the users table
+//	-- author: jane
+//	-- tags: users, auth
+//	-- ticket: APP-123
+//	-- options: no-transaction
+//	-- depends-on: 003_create_accounts.sql
+//
+// A description may span several lines, list values are comma separated and
+// may be repeated. Other comments, including unknown keys, are ignored.
+func parseHeader(migration *Migration, sql string) error {
+	var description []string
+	for _, line := range strings.Split(strings.TrimSpace(sql), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+
+		matches := headerPattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		key, value := matches[1], strings.TrimSpace(matches[2])
+		switch key {
+		case "description":
+			description = append(description, value)
+		case "author":
+			migration.Author = value
+		case "ticket":
+			migration.Ticket = value
+		case "tags":
+			migration.Tags = append(migration.Tags, splitHeaderList(value)...)
+		case "options":
+			for _, option := range splitHeaderList(value) {
+				if !knownOptions[option] {
+					return fmt.Errorf("%s: unknown option %s", migration.Name, option)
+				}
+				migration.Options = append(migration.Options, option)
+			}
+		case "depends-on":
+			for _, dep := range splitHeaderList(value) {
+				if !strings.HasSuffix(dep, ".sql") {
+					dep += ".sql"
+				}
+				migration.DependsOn = append(migration.DependsOn, dep)
+			}
+		case "squashes":
+			migration.Replaces = append(migration.Replaces, splitHeaderList(value)...)
+		}
+	}
+
+	migration.Description = strings.Join(description, " ")
+	sort.Strings(migration.Replaces)
+	return nil
+}
+
+func splitHeaderList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// HasOption reports whether option is set in the header of the migration.
+func (m *Migration) HasOption(option string) bool {
+	for _, o := range m.Options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package migrate
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// ioFS adapts an fs.FS to MigratorFS.
+type ioFS struct {
+	fsys fs.FS
+}
+
+// NewMigratorFS returns a MigratorFS reading from fsys, e.g. an embed.FS with
+// migrations compiled into the binary. Paths given to the Migrator are
+// relative to the root of fsys.
+//
+//	//go:embed migrations
+//	var migrations embed.FS
+//
+//	m, err := migrate.NewMigratorEx(ctx, conn, "public.schema_version", &migrate.MigratorOptions{
+//		MigratorFS: migrate.NewMigratorFS(migrations),
+//	})
+//	err = m.LoadMigrations("migrations")
+func NewMigratorFS(fsys fs.FS) MigratorFS {
+	return ioFS{fsys: fsys}
+}
+
+// fsPath converts a path built with filepath to the slash separated form
+// required by fs.FS.
+func fsPath(name string) string {
+	return path.Clean(filepath.ToSlash(name))
+}
+
+func (f ioFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	entries, err := fs.ReadDir(f.fsys, fsPath(dirname))
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		fi, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		fileInfos = append(fileInfos, fi)
+	}
+	return fileInfos, nil
+}
+
+func (f ioFS) ReadFile(filename string) ([]byte, error) {
+	return fs.ReadFile(f.fsys, fsPath(filename))
+}
+
+func (f ioFS) Glob(pattern string) ([]string, error) {
+	return fs.Glob(f.fsys, fsPath(pattern))
+}
@@ -0,0 +1,46 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/swelf19/pggo/v2/migrate"
+)
+
+func TestNewMigratorFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/001_create_t1.sql":             {Data: []byte("create table t1(id serial primary key);\n---- create above / drop below ----\ndrop table t1;\n")},
+		"migrations/002_create_t2.up.sql":          {Data: []byte("create table t2(id serial primary key);\n{{ template \"shared/views/v2.sql\" . }}\n")},
+		"migrations/002_create_t2.down.sql":        {Data: []byte("drop table t2;\n")},
+		"migrations/R_functions.sql":               {Data: []byte("create or replace function {{.prefix}}f() returns int language sql as 'select 1';\n")},
+		"migrations/shared/views/v2.sql":           {Data: []byte("create view {{.prefix}}v2 as select * from t2;")},
+		"migrations/should_be_ignored.sql":         {Data: []byte("select 1;\n")},
+		"migrations/seeds/001_people.sql":          {Data: []byte("insert into people values (1);\n")},
+		"migrations/001_create_people.sql.example": {Data: []byte("create table people();\n")},
+	}
+	opts := &migrate.MigratorOptions{MigratorFS: migrate.NewMigratorFS(fsys)}
+	data := map[string]interface{}{"prefix": "foo_"}
+
+	m, err := migrate.NewMigratorEx(context.Background(), nil, "schema_version", opts)
+	require.NoError(t, err)
+	m.Data = data
+
+	err = m.LoadMigrations("migrations")
+	require.NoError(t, err)
+	require.Len(t, m.Migrations, 2)
+	assert.Equal(t, "create table t2(id serial primary key);\ncreate view foo_v2 as select * from t2;", m.Migrations["002_create_t2.sql"].UpSQL)
+	assert.Equal(t, "drop table t2;", m.Migrations["002_create_t2.sql"].DownSQL)
+	require.Len(t, m.Repeatables, 1)
+
+	problems := migrate.Validate("migrations", data, opts)
+	assert.Equal(t, []migrate.ValidationError{
+		{Path: "migrations/should_be_ignored.sql", Message: "ignored, migration file names look like 001_name.sql or R_name.sql", Warning: true},
+	}, problems)
+
+	problems = migrate.Validate("missing", data, opts)
+	require.Len(t, problems, 1)
+	assert.Equal(t, "missing", problems[0].Path)
+}
@@ -0,0 +1,321 @@
+package migrate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// LintRule is a check for risky DDL in the up SQL of a migration.
+type LintRule struct {
+	Name        string
+	Description string
+	check       func(migration *Migration, stmt statement, created map[string]bool) (position int, message string)
+}
+
+// LintIssue is a statement flagged by a LintRule.
+type LintIssue struct {
+	Migration string
+	Path      string // Path is the file of the migration, empty for migrations added with AppendMigration
+	Rule      string
+	Message   string
+	SQL       string // SQL is the rendered up SQL of the migration
+	// Position is the character position of the issue in SQL, the first
+	// character is position 1. Templates may make it differ from the
+	// position in the file.
+	Position int
+}
+
+func (i LintIssue) Error() string {
+	name := i.Path
+	if name == "" {
+		name = i.Migration
+	}
+	return fmt.Sprintf("%s: %s: %s", name, i.Rule, i.Message)
+}
+
+type UnknownLintRuleError struct {
+	Rule string
+}
+
+func (e UnknownLintRuleError) Error() string {
+	return fmt.Sprintf("Unknown lint rule %s", e.Rule)
+}
+
+// statement is a single SQL statement. text is lower case with comments and
+// string literals blanked out, so offsets in text are offsets in the source.
+type statement struct {
+	offset int
+	text   string
+}
+
+var (
+	createTablePattern   = regexp.MustCompile(`\bcreate\s+(?:(?:global\s+|local\s+)?(?:temporary|temp|unlogged)\s+)?table\s+(?:if\s+not\s+exists\s+)?([\w."]+)`)
+	createIndexPattern   = regexp.MustCompile(`\Acreate\s+(?:unique\s+)?index\b(\s+concurrently\b)?`)
+	indexTablePattern    = regexp.MustCompile(`\bon\s+(?:only\s+)?([\w."]+)`)
+	alterTablePattern    = regexp.MustCompile(`\Aalter\s+table\s+(?:if\s+exists\s+)?(?:only\s+)?([\w."]+)`)
+	addColumnPattern     = regexp.MustCompile(`\badd\s+(?:column\s+)?(?:if\s+not\s+exists\s+)?([\w"]+)`)
+	notNullPattern       = regexp.MustCompile(`\bnot\s+null\b`)
+	defaultPattern       = regexp.MustCompile(`\bdefault\b`)
+	columnTypePattern    = regexp.MustCompile(`\balter\s+(?:column\s+)?[\w"]+\s+(?:set\s+data\s+)?type\b`)
+	dropPattern          = regexp.MustCompile(`\bdrop\s+(?:table|column|schema)\b`)
+	lockPattern          = regexp.MustCompile(`\Alock\b`)
+	lintIgnorePattern    = regexp.MustCompile(`--\s*pggo:lint-ignore\b([^\n]*)`)
+	leadingSpacesPattern = regexp.MustCompile(`\A\s*`)
+)
+
+// LintRules are all rules run by Lint.
+var LintRules = []LintRule{
+	{
+		Name:        "index-not-concurrent",
+		Description: "create index blocks writes to an existing table unless it is concurrent",
+		check: func(migration *Migration, stmt statement, created map[string]bool) (int, string) {
+			loc := createIndexPattern.FindStringSubmatchIndex(stmt.text)
+			if loc == nil || loc[2] >= 0 {
+				return -1, ""
+			}
+			if table := indexTablePattern.FindStringSubmatch(stmt.text); table != nil && created[tableName(table[1])] {
+				return -1, ""
+			}
+			// Concurrently is not allowed in a transaction.
+			if !migration.HasOption(OptionNoTransaction) {
+				return loc[0], "create index locks the table against writes until the migration commits"
+			}
+			return loc[0], "create index without concurrently locks the table against writes"
+		},
+	},
+	{
+		Name:        "add-column-not-null-without-default",
+		Description: "adding a not null column without a default fails on a table with rows",
+		check: func(migration *Migration, stmt statement, created map[string]bool) (int, string) {
+			table := alterTablePattern.FindStringSubmatch(stmt.text)
+			if table == nil || created[tableName(table[1])] {
+				return -1, ""
+			}
+			loc := addColumnPattern.FindStringSubmatchIndex(stmt.text)
+			if loc == nil || stmt.text[loc[2]:loc[3]] == "constraint" {
+				return -1, ""
+			}
+			if !notNullPattern.MatchString(stmt.text) || defaultPattern.MatchString(stmt.text) {
+				return -1, ""
+			}
+			return loc[0], "not null column added without a default"
+		},
+	},
+	{
+		Name:        "column-type-change",
+		Description: "changing the type of a column may rewrite the whole table",
+		check: func(migration *Migration, stmt statement, created map[string]bool) (int, string) {
+			table := alterTablePattern.FindStringSubmatch(stmt.text)
+			if table == nil || created[tableName(table[1])] {
+				return -1, ""
+			}
+			loc := columnTypePattern.FindStringIndex(stmt.text)
+			if loc == nil {
+				return -1, ""
+			}
+			return loc[0], "column type change may rewrite the table"
+		},
+	},
+	{
+		Name:        "drop-without-down",
+		Description: "dropping a table, column or schema in a migration that cannot be rolled back",
+		check: func(migration *Migration, stmt statement, created map[string]bool) (int, string) {
+			if migration.DownSQL != "" {
+				return -1, ""
+			}
+			loc := dropPattern.FindStringIndex(stmt.text)
+			if loc == nil {
+				return -1, ""
+			}
+			return loc[0], "drop in a migration without a down section"
+		},
+	},
+	{
+		Name:        "lock-table",
+		Description: "explicit lock table statements block other sessions",
+		check: func(migration *Migration, stmt statement, created map[string]bool) (int, string) {
+			loc := lockPattern.FindStringIndex(stmt.text)
+			if loc == nil {
+				return -1, ""
+			}
+			return loc[0], "explicit lock table"
+		},
+	},
+}
+
+// Lint runs all LintRules except disabled against the up SQL of the loaded
+// migrations in the order they are applied. A migration can turn rules off
+// with a comment:
+//
+//	-- pggo:lint-ignore index-not-concurrent, lock-table
+//
+// A pggo:lint-ignore comment without rule names turns all rules off.
+func (m *Migrator) Lint(disabled ...string) ([]LintIssue, error) {
+	off := make(map[string]bool)
+	for _, name := range disabled {
+		if lintRule(name) == nil {
+			return nil, UnknownLintRuleError{Rule: name}
+		}
+		off[name] = true
+	}
+
+	order, err := m.MigrationOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []LintIssue
+	for _, name := range order {
+		migration := m.Migrations[name]
+		ignored, err := lintIgnored(migration)
+		if err != nil {
+			return nil, err
+		}
+		if ignored == nil {
+			continue
+		}
+
+		statements := splitStatements(migration.UpSQL)
+		created := make(map[string]bool)
+		for _, stmt := range statements {
+			for _, match := range createTablePattern.FindAllStringSubmatch(stmt.text, -1) {
+				created[tableName(match[1])] = true
+			}
+		}
+
+		for _, stmt := range statements {
+			for _, rule := range LintRules {
+				if off[rule.Name] || ignored[rule.Name] {
+					continue
+				}
+				position, message := rule.check(migration, stmt, created)
+				if position < 0 {
+					continue
+				}
+				issues = append(issues, LintIssue{
+					Migration: name,
+					Path:      migration.Path,
+					Rule:      rule.Name,
+					Message:   message,
+					SQL:       migration.UpSQL,
+					Position:  stmt.offset + position + 1,
+				})
+			}
+		}
+	}
+
+	return issues, nil
+}
+
+func lintRule(name string) *LintRule {
+	for i := range LintRules {
+		if LintRules[i].Name == name {
+			return &LintRules[i]
+		}
+	}
+	return nil
+}
+
+// lintIgnored returns the rules turned off by pggo:lint-ignore comments in
+// the up SQL of migration, or nil if all rules are turned off.
+func lintIgnored(migration *Migration) (map[string]bool, error) {
+	ignored := make(map[string]bool)
+	for _, match := range lintIgnorePattern.FindAllStringSubmatch(migration.UpSQL, -1) {
+		names := splitHeaderList(match[1])
+		if len(names) == 0 {
+			return nil, nil
+		}
+		for _, name := range names {
+			if lintRule(name) == nil {
+				return nil, fmt.Errorf("%s: %v", migration.Name, UnknownLintRuleError{Rule: name})
+			}
+			ignored[name] = true
+		}
+	}
+	return ignored, nil
+}
+
+// splitStatements splits sql on semicolons outside of comments, quoted strings
+// and dollar quoted strings.
+func splitStatements(sql string) []statement {
+	// Lower case ASCII only so offsets in masked are offsets in sql.
+	masked := []byte(sql)
+	for i, c := range masked {
+		if 'A' <= c && c <= 'Z' {
+			masked[i] = c + 'a' - 'A'
+		}
+	}
+	blank := func(from, to int) {
+		for i := from; i < to && i < len(masked); i++ {
+			if masked[i] != '\n' {
+				masked[i] = ' '
+			}
+		}
+	}
+
+	for i := 0; i < len(masked); i++ {
+		switch {
+		case masked[i] == '-' && i+1 < len(masked) && masked[i+1] == '-':
+			end := strings.IndexByte(string(masked[i:]), '\n')
+			if end < 0 {
+				end = len(masked) - i
+			}
+			blank(i, i+end)
+			i += end
+		case masked[i] == '/' && i+1 < len(masked) && masked[i+1] == '*':
+			end := strings.Index(string(masked[i+2:]), "*/")
+			if end < 0 {
+				end = len(masked) - i - 2
+			}
+			blank(i, i+end+4)
+			i += end + 3
+		case masked[i] == '\'':
+			end := i + 1
+			for end < len(masked) && masked[end] != '\'' {
+				end++
+			}
+			blank(i, end+1)
+			i = end
+		case masked[i] == '$':
+			tag := dollarTagPattern.Find(masked[i:])
+			if tag == nil {
+				continue
+			}
+			end := strings.Index(string(masked[i+len(tag):]), string(tag))
+			if end < 0 {
+				end = len(masked) - i - len(tag)
+			}
+			blank(i, i+2*len(tag)+end)
+			i += 2*len(tag) + end - 1
+		}
+	}
+
+	var statements []statement
+	start := 0
+	for i := 0; i <= len(masked); i++ {
+		if i < len(masked) && masked[i] != ';' {
+			continue
+		}
+		text := string(masked[start:i])
+		if strings.TrimSpace(text) != "" {
+			lead := len(leadingSpacesPattern.FindString(text))
+			statements = append(statements, statement{offset: start + lead, text: text[lead:]})
+		}
+		start = i + 1
+	}
+
+	return statements
+}
+
+var dollarTagPattern = regexp.MustCompile(`\A\$(?:[a-z_][a-z0-9_]*)?\$`)
+
+// tableName returns name without quotes and schema for comparing the tables
+// named in statements.
+func tableName(name string) string {
+	name = strings.Replace(name, `"`, "", -1)
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
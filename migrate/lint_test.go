@@ -0,0 +1,77 @@
+package migrate_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/swelf19/pggo/v2/migrate"
+)
+
+func TestLint(t *testing.T) {
+	m, err := migrate.NewMigratorEx(context.Background(), nil, "schema_version", &migrate.MigratorOptions{})
+	require.NoError(t, err)
+
+	m.AppendMigration("001_create_users.sql", `create table users(id serial primary key);
+create index users_id_idx on users(id);`, "drop table users;")
+	m.AppendMigration("002_alter_users.sql", `-- Index, 'lock table' in comments and strings is fine
+create index users_email_idx on users(email);
+alter table users add column email text not null;
+alter table users add column name text not null default '';
+alter table users alter column id type bigint;
+create function f() returns void language sql as $$ lock table users $$;
+LOCK TABLE users;`, "alter table users drop column email;")
+	m.AppendMigration("003_drop_users.sql", "drop table users;", "")
+	m.AppendMigration("004_ignored.sql", `-- pggo:lint-ignore drop-without-down
+drop table users;
+create index concurrently users_id_idx on users(id);`, "")
+	m.AppendMigration("005_ignore_all.sql", `-- pggo:lint-ignore
+lock table users;`, "")
+	m.AppendMigration("006_no_transaction.sql", "create index users_name_idx on users(name);", "drop index users_name_idx;")
+	m.Migrations["006_no_transaction.sql"].Options = []string{migrate.OptionNoTransaction}
+	m.AppendMigration("007_ignore_in_down.sql", "lock table users;", "-- pggo:lint-ignore\nselect 1;")
+
+	issues, err := m.Lint()
+	require.NoError(t, err)
+
+	var found []string
+	for _, issue := range issues {
+		found = append(found, issue.Migration+" "+issue.Rule)
+	}
+	assert.Equal(t, []string{
+		"002_alter_users.sql index-not-concurrent",
+		"002_alter_users.sql add-column-not-null-without-default",
+		"002_alter_users.sql column-type-change",
+		"002_alter_users.sql lock-table",
+		"003_drop_users.sql drop-without-down",
+		"006_no_transaction.sql index-not-concurrent",
+		"007_ignore_in_down.sql lock-table",
+	}, found)
+
+	assert.Equal(t, "add column email text not null;", issues[1].SQL[issues[1].Position-1:][:31])
+	assert.Equal(t, 3, strings.Count(issues[1].SQL[:issues[1].Position-1], "\n")+1)
+	assert.Equal(t, 7, strings.Count(issues[3].SQL[:issues[3].Position-1], "\n")+1)
+	assert.Equal(t, "LOCK TABLE users;", issues[3].SQL[issues[3].Position-1:])
+	assert.Equal(t, "002_alter_users.sql: lock-table: explicit lock table", issues[3].Error())
+	assert.Equal(t, "create index locks the table against writes until the migration commits", issues[0].Message)
+	assert.Equal(t, "create index without concurrently locks the table against writes", issues[5].Message)
+
+	issues, err = m.Lint("lock-table", "index-not-concurrent")
+	require.NoError(t, err)
+	assert.Len(t, issues, 3)
+
+	m, err = migrate.NewMigratorEx(context.Background(), nil, "schema_version", &migrate.MigratorOptions{})
+	require.NoError(t, err)
+	err = m.LoadMigrations("testdata/sample")
+	require.NoError(t, err)
+	m.Migrations["001_create_t1.sql"].UpSQL += "\nlock table t1;"
+	issues, err = m.Lint()
+	require.NoError(t, err)
+	require.Len(t, issues, 2)
+	assert.Equal(t, "testdata/sample/001_create_t1.sql: lock-table: explicit lock table", issues[0].Error())
+
+	_, err = m.Lint("no-such-rule")
+	assert.Equal(t, migrate.UnknownLintRuleError{Rule: "no-such-rule"}, err)
+}
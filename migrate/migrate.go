@@ -8,7 +8,6 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
-	"sort"
 	"strings"
 	"text/template"
 
@@ -36,6 +35,14 @@ var ErrNoMigrations = errors.Errorf("no rows in result set")
 
 var migrationPattern = regexp.MustCompile(`\A(\d+)_.+\.sql\z`)
 
+var repeatablePattern = regexp.MustCompile(`\AR_.+\.sql\z`)
+
+// upPattern and downPattern match the files of a migration split into a
+// NNN_name.up.sql and NNN_name.down.sql pair. The pair is tracked as
+// NNN_name.sql, the same name as a single file migration.
+var upPattern = regexp.MustCompile(`\A(\d+_.+)\.up\.sql\z`)
+var downPattern = regexp.MustCompile(`\A(\d+_.+)\.down\.sql\z`)
+
 var ErrNoFwMigration = errors.Errorf("no sql in forward migration step")
 
 type BadVersionError string
@@ -68,6 +75,32 @@ func (e NoMigrationsFoundError) Error() string {
 	return fmt.Sprintf("No migrations found at %s", e.Path)
 }
 
+type PartiallySquashedError struct {
+	MigrationName string
+	Missing       []string
+}
+
+func (e PartiallySquashedError) Error() string {
+	return fmt.Sprintf(`Squashed migration "%s" is partially applied, missing: %s`, e.MigrationName, strings.Join(e.Missing, ", "))
+}
+
+type DuplicateMigrationError struct {
+	MigrationName string
+	Paths         []string
+}
+
+func (e DuplicateMigrationError) Error() string {
+	return fmt.Sprintf(`Migration "%s" is defined more than once: %s`, e.MigrationName, strings.Join(e.Paths, ", "))
+}
+
+type MissingUpMigrationError struct {
+	Path string
+}
+
+func (e MissingUpMigrationError) Error() string {
+	return fmt.Sprintf("Down migration %s has no matching up migration", e.Path)
+}
+
 type MigrationPgError struct {
 	Sql string
 	*pgconn.PgError
@@ -76,8 +109,18 @@ type MigrationPgError struct {
 type Migration struct {
 	Sequence int32
 	Name     string
+	Path     string // Path is the file the migration was loaded from, empty for migrations added with AppendMigration
 	UpSQL    string
 	DownSQL  string
+	Replaces []string // Replaces holds the names of the migrations squashed into this one
+
+	// Metadata from the header of the migration file
+	Description string
+	Author      string
+	Ticket      string
+	Tags        []string
+	Options     []string
+	DependsOn   []string // DependsOn holds the names of the migrations that must be applied first
 }
 
 type MigratorOptions struct {
@@ -85,6 +128,12 @@ type MigratorOptions struct {
 	DisableTx bool
 	// MigratorFS is the interface used for collecting the migrations.
 	MigratorFS MigratorFS
+	// TemplatePaths are extra directories searched recursively for shared
+	// templates, e.g. a template library used by several projects.
+	TemplatePaths []string
+	// TemplatesFS reads TemplatePaths, e.g. from the local file system while
+	// MigratorFS reads an archive. MigratorFS is used when it is nil.
+	TemplatesFS MigratorFS
 }
 
 type Migrator struct {
@@ -92,7 +141,8 @@ type Migrator struct {
 	versionTable  string
 	options       *MigratorOptions
 	Migrations    map[string]*Migration
-	OnStart       func(int32, string, string, string) // OnStart is called when a migration is run with the sequence, name, direction, and SQL
+	Repeatables   map[string]*Migration               // Repeatables are re-run after the versioned migrations whenever their SQL changes
+	OnStart       func(int32, string, string, string) // OnStart is called when a migration is run with the sequence, name, direction (up or down), and SQL
 	Data          map[string]interface{}              // Data available to use in migrations
 	fakeMigration bool                                //if true, only mark migration as applied, no actual migration
 }
@@ -103,10 +153,20 @@ func NewMigrator(ctx context.Context, conn DBConnection, versionTable string) (m
 }
 
 // NewMigratorEx initializes a new Migrator. It is highly recommended that versionTable be schema qualified.
+// conn may be nil when the Migrator is only used to load and render migrations.
+// The local file system is used when opts.MigratorFS is nil.
 func NewMigratorEx(ctx context.Context, conn DBConnection, versionTable string, opts *MigratorOptions) (m *Migrator, err error) {
+	if opts.MigratorFS == nil {
+		o := *opts
+		o.MigratorFS = defaultMigratorFS{}
+		opts = &o
+	}
 	m = &Migrator{conn: conn, versionTable: versionTable, options: opts, fakeMigration: false}
-	err = m.ensureSchemaVersionTableExists(ctx)
+	if conn != nil {
+		err = m.ensureSchemaVersionTableExists(ctx)
+	}
 	m.Migrations = make(map[string]*Migration)
+	m.Repeatables = make(map[string]*Migration)
 	m.Data = make(map[string]interface{})
 	return
 }
@@ -131,26 +191,101 @@ func (defaultMigratorFS) Glob(Pattern string) ([]string, error) {
 	return filepath.Glob(Pattern)
 }
 
-func FindMigrationsEx(path string, fs MigratorFS) ([]string, error) {
+// migrationFile holds the files of a migration. downPath is only set for the
+// up/down pair layout.
+type migrationFile struct {
+	name     string
+	upPath   string
+	downPath string
+}
+
+// MigrationName returns the name a migration file is tracked under. The files
+// of an up/down pair are tracked as NNN_name.sql.
+func MigrationName(filename string) string {
+	if matches := upPattern.FindStringSubmatch(filename); matches != nil {
+		return matches[1] + ".sql"
+	}
+	if matches := downPattern.FindStringSubmatch(filename); matches != nil {
+		return matches[1] + ".sql"
+	}
+	return filename
+}
+
+func findMigrationFiles(path string, fs MigratorFS) ([]*migrationFile, error) {
+	files, problems, err := scanMigrationFiles(path, fs)
+	if err != nil {
+		return nil, err
+	}
+	if len(problems) > 0 {
+		return nil, problems[0]
+	}
+	return files, nil
+}
+
+// scanMigrationFiles returns the migration files in path. Duplicate migrations
+// and down files without an up file are skipped and returned as problems.
+func scanMigrationFiles(path string, fs MigratorFS) ([]*migrationFile, []error, error) {
 	path = strings.TrimRight(path, string(filepath.Separator))
 
 	fileInfos, err := fs.ReadDir(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	paths := make([]string, 0, len(fileInfos))
+	files := make([]*migrationFile, 0, len(fileInfos))
+	byName := make(map[string]*migrationFile)
+	var downPaths []string
+	var problems []error
 	for _, fi := range fileInfos {
 		if fi.IsDir() {
 			continue
 		}
 
+		p := filepath.Join(path, fi.Name())
+		if downPattern.MatchString(fi.Name()) {
+			downPaths = append(downPaths, p)
+			continue
+		}
+
 		matches := migrationPattern.FindStringSubmatch(fi.Name())
 		if len(matches) != 2 {
 			continue
 		}
 
-		paths = append(paths, filepath.Join(path, fi.Name()))
+		name := MigrationName(fi.Name())
+		if f, ok := byName[name]; ok {
+			problems = append(problems, DuplicateMigrationError{MigrationName: name, Paths: []string{f.upPath, p}})
+			continue
+		}
+
+		f := &migrationFile{name: name, upPath: p}
+		byName[name] = f
+		files = append(files, f)
+	}
+
+	for _, p := range downPaths {
+		f, ok := byName[MigrationName(filepath.Base(p))]
+		if !ok || !upPattern.MatchString(filepath.Base(f.upPath)) {
+			problems = append(problems, MissingUpMigrationError{Path: p})
+			continue
+		}
+		f.downPath = p
+	}
+
+	return files, problems, nil
+}
+
+// FindMigrationsEx returns the path of every migration in path. For the up/down
+// pair layout the path of the up file is returned.
+func FindMigrationsEx(path string, fs MigratorFS) ([]string, error) {
+	files, err := findMigrationFiles(path, fs)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.upPath)
 	}
 
 	return paths, nil
@@ -160,76 +295,194 @@ func FindMigrations(path string) ([]string, error) {
 	return FindMigrationsEx(path, defaultMigratorFS{})
 }
 
-func (m *Migrator) LoadMigrations(path string) error {
-	path = strings.TrimRight(path, string(filepath.Separator))
-	mainTmpl := template.New("main")
-	sharedPaths, err := m.options.MigratorFS.Glob(filepath.Join(path, "*", "*.sql"))
+// loadSharedTemplates parses the .sql files below the subdirectories of path,
+// except the seeds directory, and below the extra template paths into tmpl. Each template is named by its
+// slash separated path relative to the directory it was found in, e.g.
+// "shared/functions/audit.sql". Templates under path are parsed last so they
+// take precedence over a library template of the same name.
+func loadSharedTemplates(opts *MigratorOptions, tmpl *template.Template, path string) error {
+	templatesFS := opts.TemplatesFS
+	if templatesFS == nil {
+		templatesFS = opts.MigratorFS
+	}
+	for _, root := range opts.TemplatePaths {
+		err := walkTemplates(templatesFS, tmpl, root, root)
+		if err != nil {
+			return err
+		}
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	fileInfos, err := opts.MigratorFS.ReadDir(path)
 	if err != nil {
 		return err
 	}
 
-	for _, p := range sharedPaths {
-		body, err := m.options.MigratorFS.ReadFile(p)
+	for _, fi := range fileInfos {
+		if !fi.IsDir() || fi.Name() == SeedsDir {
+			continue
+		}
+
+		err = walkTemplates(opts.MigratorFS, tmpl, path, filepath.Join(path, fi.Name()))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func walkTemplates(fs MigratorFS, tmpl *template.Template, root, dir string) error {
+	fileInfos, err := fs.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range fileInfos {
+		p := filepath.Join(dir, fi.Name())
+		if fi.IsDir() {
+			err = walkTemplates(fs, tmpl, root, p)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if filepath.Ext(fi.Name()) != ".sql" {
+			continue
+		}
+
+		body, err := fs.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(root, p)
 		if err != nil {
 			return err
 		}
 
-		name := strings.Replace(p, path+string(filepath.Separator), "", 1)
-		_, err = mainTmpl.New(name).Parse(string(body))
+		_, err = tmpl.New(filepath.ToSlash(name)).Parse(string(body))
 		if err != nil {
 			return err
 		}
 	}
 
-	paths, err := FindMigrationsEx(path, m.options.MigratorFS)
+	return nil
+}
+
+func (m *Migrator) LoadMigrations(path string) error {
+	path = strings.TrimRight(path, string(filepath.Separator))
+	mainTmpl := template.New("main").Funcs(TemplateFuncs())
+	err := loadSharedTemplates(m.options, mainTmpl, path)
+	if err != nil {
+		return err
+	}
+
+	files, err := findMigrationFiles(path, m.options.MigratorFS)
+	if err != nil {
+		return err
+	}
+
+	repeatablePaths, err := FindRepeatablesEx(path, m.options.MigratorFS)
 	if err != nil {
 		return err
 	}
 
-	if len(paths) == 0 {
+	if len(files) == 0 && len(repeatablePaths) == 0 {
 		return NoMigrationsFoundError{Path: path}
 	}
 
-	for _, p := range paths {
-		body, err := m.options.MigratorFS.ReadFile(p)
+	for _, p := range repeatablePaths {
+		err = m.loadRepeatable(mainTmpl, p)
 		if err != nil {
 			return err
 		}
+	}
 
-		pieces := strings.SplitN(string(body), "---- create above / drop below ----", 2)
-		var upSQL, downSQL string
-		upSQL = strings.TrimSpace(pieces[0])
-		upSQL, err = m.evalMigration(mainTmpl.New(filepath.Base(p)+" up"), upSQL)
+	for _, f := range files {
+		err = m.loadMigration(mainTmpl, f)
 		if err != nil {
 			return err
 		}
-		// Make sure there is SQL in the forward migration step.
-		containsSQL := false
-		for _, v := range strings.Split(upSQL, "\n") {
-			// Only account for regular single line comment, empty line and space/comment combination
-			cleanString := strings.TrimSpace(v)
-			if len(cleanString) != 0 &&
-				!strings.HasPrefix(cleanString, "--") {
-				containsSQL = true
-				break
-			}
+	}
+
+	// Refuse to load migrations with missing or cyclic dependencies.
+	_, err = m.MigrationOrder()
+	return err
+}
+
+func (m *Migrator) loadRepeatable(mainTmpl *template.Template, p string) error {
+	body, err := m.options.MigratorFS.ReadFile(p)
+	if err != nil {
+		return err
+	}
+
+	// Repeatable migrations are never rolled back, a down section is ignored.
+	pieces := strings.SplitN(string(body), "---- create above / drop below ----", 2)
+	upSQL, err := m.evalMigration(mainTmpl.New(filepath.Base(p)), strings.TrimSpace(pieces[0]))
+	if err != nil {
+		return err
+	}
+
+	m.AppendRepeatable(filepath.Base(p), upSQL)
+	return parseHeader(m.Repeatables[filepath.Base(p)], pieces[0])
+}
+
+func (m *Migrator) loadMigration(mainTmpl *template.Template, f *migrationFile) error {
+	body, err := m.options.MigratorFS.ReadFile(f.upPath)
+	if err != nil {
+		return err
+	}
+
+	pieces := strings.SplitN(string(body), "---- create above / drop below ----", 2)
+	if f.downPath != "" {
+		if len(pieces) == 2 {
+			return fmt.Errorf("%s: the drop below marker is not allowed in an up file", f.upPath)
 		}
-		if !containsSQL {
-			return ErrNoFwMigration
+
+		down, err := m.options.MigratorFS.ReadFile(f.downPath)
+		if err != nil {
+			return err
 		}
+		pieces = append(pieces, string(down))
+	}
 
-		if len(pieces) == 2 {
-			downSQL = strings.TrimSpace(pieces[1])
-			downSQL, err = m.evalMigration(mainTmpl.New(filepath.Base(p)+" down"), downSQL)
-			if err != nil {
-				return err
-			}
+	var upSQL, downSQL string
+	upSQL = strings.TrimSpace(pieces[0])
+	upSQL, err = m.evalMigration(mainTmpl.New(f.name+" up"), upSQL)
+	if err != nil {
+		return err
+	}
+	// Make sure there is SQL in the forward migration step.
+	containsSQL := false
+	for _, v := range strings.Split(upSQL, "\n") {
+		// Only account for regular single line comment, empty line and space/comment combination
+		cleanString := strings.TrimSpace(v)
+		if len(cleanString) != 0 &&
+			!strings.HasPrefix(cleanString, "--") {
+			containsSQL = true
+			break
 		}
+	}
+	if !containsSQL {
+		return ErrNoFwMigration
+	}
 
-		m.AppendMigration(filepath.Base(p), upSQL, downSQL)
+	if len(pieces) == 2 {
+		downSQL = strings.TrimSpace(pieces[1])
+		downSQL, err = m.evalMigration(mainTmpl.New(f.name+" down"), downSQL)
+		if err != nil {
+			return err
+		}
 	}
 
-	return nil
+	m.AppendMigration(f.name, upSQL, downSQL)
+	m.Migrations[f.name].Path = f.upPath
+	return parseHeader(m.Migrations[f.name], pieces[0])
 }
 
 func (m *Migrator) evalMigration(tmpl *template.Template, sql string) (string, error) {
@@ -257,17 +510,20 @@ func (m *Migrator) AppendMigration(name, upSQL, downSQL string) {
 	return
 }
 
-// Migrate runs pending migrations
+// Migrate runs pending migrations followed by changed repeatable migrations
 // It calls m.OnStart when it begins a migration
 func (m *Migrator) Migrate(ctx context.Context) error {
 	migrations, err := m.MigrationsToApply(ctx)
 	if err != nil {
 		return err
 	}
-	if len(migrations) == 0 {
-		return nil
+	if len(migrations) > 0 {
+		err = m.MigrateTo(ctx, migrations[len(migrations)-1])
+		if err != nil {
+			return err
+		}
 	}
-	return m.MigrateTo(ctx, migrations[len(migrations)-1])
+	return m.MigrateRepeatables(ctx)
 }
 
 // Lock to ensure multiple migrations cannot occur simultaneously
@@ -284,25 +540,20 @@ func (m *Migrator) releaseAdvisoryLock(ctx context.Context) error {
 }
 
 func (m *Migrator) MigrationsToApply(ctx context.Context) ([]string, error) {
-	currentMigrations, err := m.GetCurrentVersion(ctx)
-	if err != nil && err != ErrNoMigrations {
+	currentMigrations, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return []string{}, err
+	}
+	order, err := m.MigrationOrder()
+	if err != nil {
 		return []string{}, err
 	}
-	var found bool
 	toApply := []string{}
-	for _, migrationCandidate := range m.Migrations {
-		found = false
-		for _, migrationApplied := range currentMigrations {
-			if migrationCandidate.Name == migrationApplied {
-				found = true
-				break
-			}
-		}
-		if !found {
-			toApply = append(toApply, migrationCandidate.Name)
+	for _, name := range order {
+		if Position(currentMigrations, name) < 0 {
+			toApply = append(toApply, name)
 		}
 	}
-	sort.Strings(toApply)
 	return toApply, nil
 }
 
@@ -322,9 +573,8 @@ func Reverse(s []string) {
 }
 
 func (m *Migrator) GetDirection(ctx context.Context, targetMigration string) (MigraionDirection, error) {
-	currentMigrations, err := m.GetCurrentVersion(ctx)
-
-	if err != nil && err != ErrNoMigrations {
+	currentMigrations, err := m.appliedMigrations(ctx)
+	if err != nil {
 		return Back, err
 	}
 	_, foundNew := m.Migrations[targetMigration]
@@ -364,95 +614,117 @@ func (m *Migrator) MigrateTo(ctx context.Context, targetMigration string) (err e
 			return err
 		}
 	} else if direction == Back {
-		currentMigrations, err := m.GetCurrentVersion(ctx)
+		currentMigrations, err := m.appliedMigrations(ctx)
 		if err != nil {
 			return err
 		}
 		Reverse(currentMigrations)
 		migrationsToApply = currentMigrations[:Position(currentMigrations, targetMigration)]
+		err = m.checkRollback(currentMigrations, migrationsToApply)
+		if err != nil {
+			return err
+		}
 	} else if direction == NotFound {
 		return MigrationNotFound{MigrationName: targetMigration}
 	}
 
 	for _, currentName := range migrationsToApply {
-		var current *Migration
-		var sql, directionName string
-		current = m.Migrations[currentName]
-		if direction == Forward {
-			sql = current.UpSQL
-		} else {
-			sql = current.DownSQL
-		}
-
-		var tx pgx.Tx
-		if !m.options.DisableTx {
-			tx, err = m.conn.Begin(ctx)
-			if err != nil {
-				return err
-			}
-			defer tx.Rollback(ctx)
+		err = m.applyMigration(ctx, m.Migrations[currentName], direction)
+		if err != nil {
+			return err
 		}
 
-		// Fire on start callback
-		if m.OnStart != nil {
-			m.OnStart(current.Sequence, current.Name, directionName, sql)
-		}
-		if sql != "" && !m.fakeMigration {
-			// Execute the migration
-			_, err = m.conn.Exec(ctx, sql)
-			if err != nil {
-				if err, ok := err.(*pgconn.PgError); ok {
-					return MigrationPgError{Sql: sql, PgError: err}
-				}
-				return err
-			}
+		if targetMigration == currentName {
+			//we have done with migrations, exiting
+			return nil
 		}
 
-		// Reset all database connection settings. Important to do before updating version as search_path may have been changed.
-		m.conn.Exec(ctx, "reset all")
+	}
 
-		// Add one to the version
-		if direction == Forward {
-			err = m.markMigrationApplied(ctx, current.Name)
-		} else {
-			err = m.markMigrationUnapplied(ctx, current.Name)
-		}
+	return nil
+}
+
+// applyMigration runs the up or down SQL of current and records the result in
+// the version table. The caller holds the advisory lock.
+func (m *Migrator) applyMigration(ctx context.Context, current *Migration, direction MigraionDirection) (err error) {
+	var sql, directionName string
+	if direction == Forward {
+		sql = current.UpSQL
+		directionName = "up"
+	} else {
+		sql = current.DownSQL
+		directionName = "down"
+	}
+
+	var tx pgx.Tx
+	useTx := !m.options.DisableTx && !current.HasOption(OptionNoTransaction)
+	if useTx {
+		tx, err = m.conn.Begin(ctx)
 		if err != nil {
 			return err
 		}
-		if !m.options.DisableTx {
-			err = tx.Commit(ctx)
-			if err != nil {
-				return err
+		defer tx.Rollback(ctx)
+	}
+
+	// Fire on start callback
+	if m.OnStart != nil {
+		m.OnStart(current.Sequence, current.Name, directionName, sql)
+	}
+	if sql != "" && !m.fakeMigration {
+		// Execute the migration
+		_, err = m.conn.Exec(ctx, sql)
+		if err != nil {
+			if err, ok := err.(*pgconn.PgError); ok {
+				return MigrationPgError{Sql: sql, PgError: err}
 			}
+			return err
 		}
+	}
 
-		if targetMigration == currentName {
-			//we have done with migrations, exiting
-			return nil
-		}
+	// Reset all database connection settings. Important to do before updating version as search_path may have been changed.
+	m.conn.Exec(ctx, "reset all")
 
+	// Add one to the version
+	if direction == Forward {
+		err = m.markMigrationApplied(ctx, current)
+	} else {
+		err = m.markMigrationUnapplied(ctx, append([]string{current.Name}, current.Replaces...)...)
+	}
+	if err != nil {
+		return err
+	}
+	if useTx {
+		err = tx.Commit(ctx)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (m *Migrator) markMigrationApplied(ctx context.Context, migrationsName string) error {
-	query := fmt.Sprintf("insert into %s (migration_name,migrated_at) values ($1,now())", m.versionTable)
+func (m *Migrator) markMigrationApplied(ctx context.Context, migration *Migration) error {
+	query := fmt.Sprintf("insert into %s (migration_name,migrated_at,checksum,description,author,ticket,tags,options) values ($1,now(),$2,nullif($3,''),nullif($4,''),nullif($5,''),$6,$7)", m.versionTable)
 	_, err := m.conn.Exec(ctx,
 		query,
-		migrationsName,
+		migration.Name,
+		migration.Checksum(),
+		migration.Description,
+		migration.Author,
+		migration.Ticket,
+		migration.Tags,
+		migration.Options,
 	)
 	if err != nil {
 		return err
 	}
 	return nil
 }
-func (m *Migrator) markMigrationUnapplied(ctx context.Context, migrationsName string) error {
-	query := fmt.Sprintf("delete from %s where migration_name=$1", m.versionTable)
+func (m *Migrator) markMigrationUnapplied(ctx context.Context, migrationsNames ...string) error {
+	query := fmt.Sprintf("delete from %s where migration_name=any($1)", m.versionTable)
 	_, err := m.conn.Exec(ctx,
 		query,
-		migrationsName,
+		migrationsNames,
 	)
 	if err != nil {
 		return err
@@ -463,7 +735,7 @@ func (m *Migrator) markMigrationUnapplied(ctx context.Context, migrationsName st
 func (m *Migrator) GetCurrentVersion(ctx context.Context) (v []string, err error) {
 	migrations := make([]string, 0)
 	rows, err := m.conn.Query(ctx,
-		fmt.Sprintf("select migration_name from %s order by migrated_at",
+		fmt.Sprintf("select migration_name from %s where not repeatable order by migrated_at",
 			m.versionTable),
 	)
 	defer rows.Close()
@@ -478,6 +750,48 @@ func (m *Migrator) GetCurrentVersion(ctx context.Context) (v []string, err error
 	return migrations, err
 }
 
+// appliedMigrations returns the applied migrations in the order they were
+// applied. A squashed migration counts as applied once the last of the
+// migrations it replaces is applied, and is reported in its place. Any other
+// replaced migrations are left out.
+func (m *Migrator) appliedMigrations(ctx context.Context) ([]string, error) {
+	currentMigrations, err := m.GetCurrentVersion(ctx)
+	if err != nil && err != ErrNoMigrations {
+		return nil, err
+	}
+
+	replacedBy := make(map[string]*Migration)
+	for _, migration := range m.Migrations {
+		for _, name := range migration.Replaces {
+			replacedBy[name] = migration
+		}
+	}
+	if len(replacedBy) == 0 {
+		return currentMigrations, nil
+	}
+
+	applied := make([]string, 0, len(currentMigrations))
+	for _, name := range currentMigrations {
+		squashed, ok := replacedBy[name]
+		if !ok {
+			if Position(applied, name) < 0 {
+				applied = append(applied, name)
+			}
+			continue
+		}
+
+		last := squashed.Replaces[len(squashed.Replaces)-1]
+		if Position(currentMigrations, last) < 0 && Position(currentMigrations, squashed.Name) < 0 {
+			return nil, PartiallySquashedError{MigrationName: squashed.Name, Missing: []string{last}}
+		}
+		if name == last && Position(applied, squashed.Name) < 0 {
+			applied = append(applied, squashed.Name)
+		}
+	}
+
+	return applied, nil
+}
+
 func (m *Migrator) ensureSchemaVersionTableExists(ctx context.Context) (err error) {
 	err = m.acquireAdvisoryLock(ctx)
 	if err != nil {
@@ -490,14 +804,14 @@ func (m *Migrator) ensureSchemaVersionTableExists(ctx context.Context) (err erro
 		}
 	}()
 	exists := m.isMigrationTableExists(ctx)
-	if exists == true {
-		return nil
+	if exists == false {
+		err = m.createMigrationTable(ctx)
+		if err != nil {
+			return err
+		}
 	}
 
-	err = m.createMigrationTable(ctx)
-
-	return err
-
+	return m.upgradeMigrationTable(ctx)
 }
 
 func (m *Migrator) isMigrationTableExists(ctx context.Context) bool {
@@ -523,3 +837,62 @@ func (m *Migrator) createMigrationTable(ctx context.Context) (err error) {
 	 `, m.versionTable))
 	return err
 }
+
+// versionTableColumns are the columns added to the version table after it
+// was first created, with their definitions.
+var versionTableColumns = []struct {
+	name       string
+	definition string
+}{
+	{"checksum", "text"},
+	{"repeatable", "boolean not null default false"},
+	{"description", "text"},
+	{"author", "text"},
+	{"ticket", "text"},
+	{"tags", "text[]"},
+	{"options", "text[]"},
+}
+
+// upgradeMigrationTable adds the versionTableColumns missing from the version
+// table. The table is only altered when a column is missing, so roles that do
+// not own it can still read it.
+func (m *Migrator) upgradeMigrationTable(ctx context.Context) (err error) {
+	rows, err := m.conn.Query(ctx, `
+	select column_name from information_schema.columns
+	where (table_schema, table_name) = (
+		select n.nspname, c.relname
+		from pg_class c
+		join pg_namespace n on n.oid = c.relnamespace
+		where c.oid = $1::text::regclass
+	)`, m.versionTable)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+
+	var missing []string
+	for _, column := range versionTableColumns {
+		if !existing[column.name] {
+			missing = append(missing, fmt.Sprintf("add column if not exists %s %s", column.name, column.definition))
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	_, err = m.conn.Exec(ctx, fmt.Sprintf("alter table %s %s", m.versionTable, strings.Join(missing, ", ")))
+	return err
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/jackc/pgx/v4"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"github.com/swelf19/pggo/v2/migrate"
 )
@@ -190,6 +191,181 @@ func (suite *MigrateTestSuite) TestForwardBackMigration() {
 	suite.Equal(false, suite.isTableExists("t2"), "t2 exists")
 	suite.Equal(false, suite.isTableExists("t3"), "t3 exists")
 }
+
+func (suite *MigrateTestSuite) TestDependentRollback() {
+	suite.m.Migrations = make(map[string]*migrate.Migration)
+	suite.m.AppendMigration("migration_1", "create table t1(id serial primary key);", "drop table if exists t1;")
+	suite.m.AppendMigration("migration_2", "create table t2(id serial primary key);", "drop table if exists t2;")
+	suite.m.AppendMigration("migration_3", "create table t3(id serial primary key);", "drop table if exists t3;")
+
+	err := suite.m.MigrateTo(context.Background(), "migration_3")
+	suite.Require().NoError(err, suite.T())
+
+	suite.m.Migrations["migration_2"].DependsOn = []string{"migration_3"}
+	err = suite.m.MigrateTo(context.Background(), "migration_2")
+	suite.Equal(migrate.DependentMigrationError{MigrationName: "migration_3", Dependents: []string{"migration_2"}}, err)
+	suite.Equal(true, suite.isTableExists("t3"), "t3 exists")
+
+	err = suite.m.MigrateTo(context.Background(), "migration_1")
+	suite.Require().NoError(err, suite.T())
+	suite.Equal(false, suite.isTableExists("t3"), "t3 exists")
+}
+
+func (suite *MigrateTestSuite) TestVerify() {
+	suite.m.Migrations = make(map[string]*migrate.Migration)
+	suite.m.AppendMigration("migration_1", "create table t1(id serial primary key);", "drop table t1;")
+	suite.m.AppendMigration("migration_2", "create table t2(id serial primary key);", "alter table t2 rename to t2_old;")
+	suite.m.AppendMigration("migration_3", "drop table t1;", "")
+	suite.m.AppendMigration("migration_4", "create table t3(id serial primary key);", "select * from no_such_table;")
+
+	results, err := suite.m.Verify(context.Background())
+	suite.Require().NoError(err, suite.T())
+	suite.Require().Len(results, 4)
+	suite.True(results[0].OK())
+	suite.False(results[1].OK())
+	suite.Contains(results[1].DownChanges, migrate.CatalogChange{Object: "table public.t2_old", After: "(exists)"})
+	suite.Contains(results[1].UpChanges, migrate.CatalogChange{Object: "table public.t2_old", After: "(exists)"})
+	suite.True(results[2].Irreversible)
+	suite.Error(results[3].Err)
+}
+
+func (suite *MigrateTestSuite) TestSquashedMigration() {
+	suite.m.Migrations = make(map[string]*migrate.Migration)
+	suite.m.AppendMigration("migration_1", "create table t1(id serial primary key);", "drop table if exists t1;")
+	suite.m.AppendMigration("migration_2", "create table t2(id serial primary key);", "drop table if exists t2;")
+
+	err := suite.m.MigrateTo(context.Background(), "migration_2")
+	suite.Require().NoError(err, suite.T())
+
+	suite.m.Migrations = make(map[string]*migrate.Migration)
+	suite.m.AppendMigration("migration_2_squashed", "create table t1(id serial primary key);\ncreate table t2(id serial primary key);", "")
+	suite.m.Migrations["migration_2_squashed"].Replaces = []string{"migration_1", "migration_2"}
+	suite.m.AppendMigration("migration_3", "create table t3(id serial primary key);", "drop table if exists t3;")
+
+	needToApply, err := suite.m.MigrationsToApply(context.Background())
+	suite.Require().NoError(err, suite.T())
+	suite.Equal([]string{"migration_3"}, needToApply)
+
+	err = suite.m.Migrate(context.Background())
+	suite.Require().NoError(err, suite.T())
+	suite.Equal(true, suite.isTableExists("t3"), "t3 exists")
+}
+
+func (suite *MigrateTestSuite) TestRepeatableMigrations() {
+	err := suite.m.LoadMigrations("testdata/repeatable/")
+	suite.Require().NoError(err, suite.T())
+	suite.Equal(1, len(suite.m.Migrations))
+	suite.Equal(1, len(suite.m.Repeatables))
+
+	err = suite.m.Migrate(context.Background())
+	suite.Require().NoError(err, suite.T())
+
+	needToApply, err := suite.m.RepeatablesToApply(context.Background())
+	suite.Require().NoError(err, suite.T())
+	suite.Equal(0, len(needToApply))
+
+	currentMigrations, err := suite.m.GetCurrentVersion(context.Background())
+	suite.Require().NoError(err, suite.T())
+	suite.Equal([]string{"001_create_t1.sql"}, currentMigrations)
+
+	suite.m.Repeatables["R_v1.sql"].UpSQL = "create or replace view v1 as select id from t1;"
+	needToApply, err = suite.m.RepeatablesToApply(context.Background())
+	suite.Require().NoError(err, suite.T())
+	suite.Equal([]string{"R_v1.sql"}, needToApply)
+}
+
+func (suite *MigrateTestSuite) TestStatus() {
+	ctx := context.Background()
+	err := suite.m.LoadMigrations("testdata/repeatable/")
+	suite.Require().NoError(err, suite.T())
+	suite.m.AppendMigration("002_create_t2.sql", "create table t2(id serial primary key);", "")
+
+	report, err := suite.m.Status(ctx)
+	suite.Require().NoError(err, suite.T())
+	suite.False(report.UpToDate())
+	suite.Equal(2, report.Pending)
+	suite.Equal(1, report.PendingRepeatables)
+	suite.Equal([]string{"001_create_t1.sql", "002_create_t2.sql"}, report.PendingMigrations())
+	suite.True(report.Migrations[0].Reversible)
+	suite.False(report.Migrations[1].Reversible)
+
+	err = suite.m.Migrate(ctx)
+	suite.Require().NoError(err, suite.T())
+	_, err = suite.conn.Exec(ctx, `insert into schema_version (migration_name, migrated_at, description, author, tags)
+	values ('000_removed.sql', now(), 'Create the legacy tables', 'jane', '{legacy}')`)
+	suite.Require().NoError(err, suite.T())
+	suite.m.Migrations["002_create_t2.sql"].UpSQL = "create table t2(id bigserial primary key);"
+
+	report, err = suite.m.Status(ctx)
+	suite.Require().NoError(err, suite.T())
+	suite.True(report.UpToDate())
+	suite.Equal(2, report.Applied)
+	suite.Equal(1, report.Missing)
+	suite.Equal(1, report.Modified)
+	suite.Equal(0, report.PendingRepeatables)
+	suite.Require().Len(report.Migrations, 3)
+	suite.Equal(migrate.StateApplied, report.Migrations[0].State)
+	suite.Equal(migrate.ChecksumMatch, report.Migrations[0].Checksum)
+	suite.False(report.Migrations[0].AppliedAt.IsZero())
+	suite.Equal(migrate.ChecksumMismatch, report.Migrations[1].Checksum)
+	suite.Equal(migrate.MigrationStatus{
+		Name:        "000_removed.sql",
+		Description: "Create the legacy tables",
+		State:       migrate.StateMissing,
+		AppliedAt:   report.Migrations[2].AppliedAt,
+		Checksum:    migrate.ChecksumUnknown,
+		Author:      "jane",
+		Tags:        []string{"legacy"},
+	}, report.Migrations[2])
+}
+
+func (suite *MigrateTestSuite) TestMigrateRecordsHeader() {
+	ctx := context.Background()
+	err := suite.m.LoadMigrations("testdata/header")
+	suite.Require().NoError(err, suite.T())
+	// The index is created concurrently, which the test transaction does not
+	// allow.
+	delete(suite.m.Migrations, "002_index_users_email.sql")
+
+	err = suite.m.Migrate(ctx)
+	suite.Require().NoError(err, suite.T())
+
+	var author, ticket string
+	var tags []string
+	err = suite.conn.QueryRow(ctx, "select author, ticket, tags from schema_version where migration_name = '001_create_users.sql'").Scan(&author, &ticket, &tags)
+	suite.Require().NoError(err, suite.T())
+	suite.Equal("jane", author)
+	suite.Equal("APP-123", ticket)
+	suite.Equal([]string{"users", "auth", "signup"}, tags)
+}
+
+func (suite *MigrateTestSuite) TestSeeds() {
+	ctx := context.Background()
+	_, err := suite.conn.Exec(ctx, "create table people(name text primary key)")
+	suite.Require().NoError(err, suite.T())
+
+	seeder, err := migrate.NewSeeder(ctx, suite.conn, "schema_seed")
+	suite.Require().NoError(err, suite.T())
+	seeder.Data["admin"] = "joe"
+
+	err = seeder.LoadSeeds("testdata/seeds/", "dev")
+	suite.Require().NoError(err, suite.T())
+	suite.Equal(2, len(seeder.Seeds))
+	suite.Equal("dev/001_people.sql", seeder.Seeds[1].Name)
+
+	err = seeder.Seed(ctx, false)
+	suite.Require().NoError(err, suite.T())
+
+	needToApply, err := seeder.SeedsToApply(ctx)
+	suite.Require().NoError(err, suite.T())
+	suite.Equal(0, len(needToApply))
+
+	var count int
+	err = suite.conn.QueryRow(ctx, "select count(*) from people").Scan(&count)
+	suite.Require().NoError(err, suite.T())
+	suite.Equal(2, count)
+}
+
 func (suite *MigrateTestSuite) TestSchemaVersionInitialization() {
 	var err error
 	_, err = suite.conn.Exec(context.Background(), "drop table if exists "+"schema_version")
@@ -199,6 +375,19 @@ func (suite *MigrateTestSuite) TestSchemaVersionInitialization() {
 	suite.NoError(err)
 }
 
+func (suite *MigrateTestSuite) TestSchemaVersionUpgrade() {
+	ctx := context.Background()
+	_, err := suite.conn.Exec(ctx, "alter table schema_version drop column description")
+	suite.Require().NoError(err, suite.T())
+	_, err = migrate.NewMigrator(ctx, suite.conn, "public.schema_version")
+	suite.Require().NoError(err, suite.T())
+
+	var count int
+	err = suite.conn.QueryRow(ctx, "select count(*) from information_schema.columns where table_name = 'schema_version' and column_name = 'description'").Scan(&count)
+	suite.Require().NoError(err, suite.T())
+	suite.Equal(1, count)
+}
+
 func (suite *MigrateTestSuite) TestWrongMigration() {
 	suite.m.Migrations = make(map[string]*migrate.Migration)
 	err := suite.m.MigrateTo(context.Background(), "migration_3")
@@ -243,3 +432,90 @@ func TestHelpers(t *testing.T) {
 	assert.Equal(t, []string{}, a)
 
 }
+
+func TestLoadMigrationsSharedTemplates(t *testing.T) {
+	m, err := migrate.NewMigratorEx(context.Background(), nil, "schema_version", &migrate.MigratorOptions{
+		TemplatePaths: []string{"testdata/template_lib"},
+	})
+	require.NoError(t, err)
+	m.Data = map[string]interface{}{"prefix": "foo_"}
+
+	err = m.LoadMigrations("testdata/templates")
+	require.NoError(t, err)
+
+	mig := m.Migrations["001_create_audit.sql"]
+	require.NotNil(t, mig)
+	assert.Contains(t, mig.UpSQL, "changed_at timestamptz not null")
+	assert.Contains(t, mig.UpSQL, "create function foo_audit()")
+}
+
+func TestLoadMigrationsUpDownFiles(t *testing.T) {
+	m, err := migrate.NewMigratorEx(context.Background(), nil, "schema_version", &migrate.MigratorOptions{})
+	require.NoError(t, err)
+
+	err = m.LoadMigrations("testdata/split")
+	require.NoError(t, err)
+
+	require.Len(t, m.Migrations, 3)
+	assert.Equal(t, "create table t1(\n  id serial primary key\n);", m.Migrations["001_create_t1.sql"].UpSQL)
+	assert.Equal(t, "drop table t1;", m.Migrations["001_create_t1.sql"].DownSQL)
+	assert.Equal(t, "drop table t2;", m.Migrations["002_create_t2.sql"].DownSQL)
+	assert.Equal(t, "", m.Migrations["003_irreversible.sql"].DownSQL)
+	assert.Equal(t, int32(3), m.Migrations["003_irreversible.sql"].Sequence)
+
+	paths, err := migrate.FindMigrations("testdata/split")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"testdata/split/001_create_t1.up.sql",
+		"testdata/split/002_create_t2.sql",
+		"testdata/split/003_irreversible.up.sql",
+	}, paths)
+
+	_, err = migrate.FindMigrations("testdata/split_orphan")
+	assert.Equal(t, migrate.MissingUpMigrationError{Path: "testdata/split_orphan/001_create_t1.down.sql"}, err)
+
+	_, err = migrate.FindMigrations("testdata/split_duplicate")
+	assert.IsType(t, migrate.DuplicateMigrationError{}, err)
+	assert.Equal(t, "002_create_t2.sql", err.(migrate.DuplicateMigrationError).MigrationName)
+}
+
+func TestLoadMigrationsHeader(t *testing.T) {
+	m, err := migrate.NewMigratorEx(context.Background(), nil, "schema_version", &migrate.MigratorOptions{})
+	require.NoError(t, err)
+
+	err = m.LoadMigrations("testdata/header")
+	require.NoError(t, err)
+
+	users := m.Migrations["001_create_users.sql"]
+	assert.Equal(t, "Create the users table and its email index", users.Description)
+	assert.Equal(t, "jane", users.Author)
+	assert.Equal(t, "APP-123", users.Ticket)
+	assert.Equal(t, []string{"users", "auth", "signup"}, users.Tags)
+	assert.Empty(t, users.Options)
+	assert.False(t, users.HasOption(migrate.OptionNoTransaction))
+
+	index := m.Migrations["002_index_users_email.sql"]
+	assert.Equal(t, "Index users by email", index.Description)
+	assert.True(t, index.HasOption(migrate.OptionNoTransaction))
+}
+
+func TestMigrationOrder(t *testing.T) {
+	m, err := migrate.NewMigratorEx(context.Background(), nil, "schema_version", &migrate.MigratorOptions{})
+	require.NoError(t, err)
+
+	err = m.LoadMigrations("testdata/depends")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"002_create_users.sql"}, m.Migrations["001_create_accounts.sql"].DependsOn)
+
+	order, err := m.MigrationOrder()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"002_create_users.sql", "001_create_accounts.sql", "003_create_orders.sql"}, order)
+
+	m.Migrations = make(map[string]*migrate.Migration)
+	err = m.LoadMigrations("testdata/depends_cycle")
+	assert.Equal(t, migrate.DependencyCycleError{MigrationNames: []string{"001_a.sql", "002_b.sql"}}, err)
+
+	m.Migrations = make(map[string]*migrate.Migration)
+	err = m.LoadMigrations("testdata/depends_missing")
+	assert.Equal(t, migrate.MissingDependencyError{MigrationName: "001_a.sql", Dependency: "005_missing.sql"}, err)
+}
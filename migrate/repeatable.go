@@ -0,0 +1,191 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// Checksum returns the hex encoded SHA-256 of the rendered up SQL.
+func (m *Migration) Checksum() string {
+	return checksum(m.UpSQL)
+}
+
+// checksum returns the hex encoded SHA-256 of sql.
+func checksum(sql string) string {
+	sum := sha256.Sum256([]byte(sql))
+	return hex.EncodeToString(sum[:])
+}
+
+// FindRepeatablesEx returns the paths of the repeatable migrations in path.
+// Repeatable migrations are named R_<name>.sql.
+func FindRepeatablesEx(path string, fs MigratorFS) ([]string, error) {
+	path = strings.TrimRight(path, string(filepath.Separator))
+
+	fileInfos, err := fs.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0)
+	for _, fi := range fileInfos {
+		if fi.IsDir() || !repeatablePattern.MatchString(fi.Name()) {
+			continue
+		}
+
+		paths = append(paths, filepath.Join(path, fi.Name()))
+	}
+
+	return paths, nil
+}
+
+func (m *Migrator) AppendRepeatable(name, sql string) {
+	m.Repeatables[name] = &Migration{
+		Sequence: int32(len(m.Repeatables)) + 1,
+		Name:     name,
+		UpSQL:    sql,
+	}
+}
+
+// RepeatablesToApply returns the repeatable migrations that were never applied
+// or whose checksum differs from the one recorded when they were last applied.
+func (m *Migrator) RepeatablesToApply(ctx context.Context) ([]string, error) {
+	applied, err := m.appliedRepeatables(ctx)
+	if err != nil {
+		return []string{}, err
+	}
+
+	toApply := []string{}
+	for name, repeatable := range m.Repeatables {
+		if applied[name] != repeatable.Checksum() {
+			toApply = append(toApply, name)
+		}
+	}
+	sort.Strings(toApply)
+	return toApply, nil
+}
+
+// MigrateRepeatables runs the repeatable migrations returned by
+// RepeatablesToApply in name order.
+// It calls m.OnStart when it begins a migration
+func (m *Migrator) MigrateRepeatables(ctx context.Context) (err error) {
+	if len(m.Repeatables) == 0 {
+		return nil
+	}
+
+	err = m.acquireAdvisoryLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		unlockErr := m.releaseAdvisoryLock(ctx)
+		if err == nil && unlockErr != nil {
+			err = unlockErr
+		}
+	}()
+
+	names, err := m.RepeatablesToApply(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		err = m.applyRepeatable(ctx, m.Repeatables[name])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) applyRepeatable(ctx context.Context, current *Migration) error {
+	var tx pgx.Tx
+	var err error
+	useTx := !m.options.DisableTx && !current.HasOption(OptionNoTransaction)
+	if useTx {
+		tx, err = m.conn.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+	}
+
+	if m.OnStart != nil {
+		m.OnStart(current.Sequence, current.Name, "repeatable", current.UpSQL)
+	}
+	if !m.fakeMigration {
+		_, err = m.conn.Exec(ctx, current.UpSQL)
+		if err != nil {
+			if err, ok := err.(*pgconn.PgError); ok {
+				return MigrationPgError{Sql: current.UpSQL, PgError: err}
+			}
+			return err
+		}
+	}
+
+	// Reset all database connection settings. Important to do before updating version as search_path may have been changed.
+	m.conn.Exec(ctx, "reset all")
+
+	err = m.markRepeatableApplied(ctx, current)
+	if err != nil {
+		return err
+	}
+
+	if useTx {
+		return tx.Commit(ctx)
+	}
+	return nil
+}
+
+func (m *Migrator) markRepeatableApplied(ctx context.Context, migration *Migration) error {
+	_, err := m.conn.Exec(ctx,
+		fmt.Sprintf("delete from %s where repeatable and migration_name=$1", m.versionTable),
+		migration.Name,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.conn.Exec(ctx,
+		fmt.Sprintf("insert into %s (migration_name,migrated_at,checksum,repeatable,description,author,ticket,tags,options) values ($1,now(),$2,true,nullif($3,''),nullif($4,''),nullif($5,''),$6,$7)", m.versionTable),
+		migration.Name,
+		migration.Checksum(),
+		migration.Description,
+		migration.Author,
+		migration.Ticket,
+		migration.Tags,
+		migration.Options,
+	)
+	return err
+}
+
+// appliedRepeatables returns the checksum recorded for each applied
+// repeatable migration.
+func (m *Migrator) appliedRepeatables(ctx context.Context) (map[string]string, error) {
+	rows, err := m.conn.Query(ctx,
+		fmt.Sprintf("select migration_name, coalesce(checksum, '') from %s where repeatable", m.versionTable),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[string]string)
+	for rows.Next() {
+		var name, checksum string
+		err = rows.Scan(&name, &checksum)
+		if err != nil {
+			return nil, err
+		}
+		applied[name] = checksum
+	}
+	return applied, rows.Err()
+}
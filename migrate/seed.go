@@ -0,0 +1,229 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// SeedsDir is the directory of the migrations path seeds are read from by
+// default. It is not searched for shared templates.
+const SeedsDir = "seeds"
+
+// Seed is a SQL file that loads data. Unlike a migration it has no down
+// section and it is run again whenever its rendered SQL changes.
+type Seed struct {
+	Name string // Name is the path of the seed relative to the seeds directory
+	SQL  string
+}
+
+// Checksum returns the hex encoded SHA-256 of the rendered SQL.
+func (s *Seed) Checksum() string {
+	return checksum(s.SQL)
+}
+
+type Seeder struct {
+	conn      DBConnection
+	seedTable string
+	options   *MigratorOptions
+	Seeds     []*Seed                // Seeds in the order they are run
+	OnStart   func(string, string)   // OnStart is called when a seed is run with the name and SQL
+	Data      map[string]interface{} // Data available to use in seeds
+}
+
+// NewSeeder initializes a new Seeder. Applied seeds are tracked in seedTable
+// which is kept apart from the version table. It is highly recommended that
+// seedTable be schema qualified.
+func NewSeeder(ctx context.Context, conn DBConnection, seedTable string) (*Seeder, error) {
+	return NewSeederEx(ctx, conn, seedTable, &MigratorOptions{MigratorFS: defaultMigratorFS{}})
+}
+
+// NewSeederEx initializes a new Seeder. It is highly recommended that seedTable be schema qualified.
+func NewSeederEx(ctx context.Context, conn DBConnection, seedTable string, opts *MigratorOptions) (*Seeder, error) {
+	if opts.MigratorFS == nil {
+		o := *opts
+		o.MigratorFS = defaultMigratorFS{}
+		opts = &o
+	}
+	s := &Seeder{conn: conn, seedTable: seedTable, options: opts, Data: make(map[string]interface{})}
+	_, err := conn.Exec(ctx, fmt.Sprintf(`
+	create table if not exists %s(
+		id serial primary key,
+		seed_name character varying(255) not null unique,
+		checksum text not null,
+		seeded_at timestamp with time zone)
+	 `, seedTable))
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadSeeds loads the seeds in path followed by the seeds in the set
+// subdirectory of path, each in name order. set may be empty to only load the
+// seeds shared by all sets.
+func (s *Seeder) LoadSeeds(path, set string) error {
+	path = strings.TrimRight(path, string(filepath.Separator))
+	dirs := []string{path}
+	if set != "" {
+		dirs = append(dirs, filepath.Join(path, set))
+	}
+
+	tmpl := template.New("main").Funcs(TemplateFuncs())
+	err := loadSharedTemplates(s.options, tmpl, "")
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		fileInfos, err := s.options.MigratorFS.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) && dir != path {
+				return fmt.Errorf("seed set %s not found at %s", set, dir)
+			}
+			return err
+		}
+
+		for _, fi := range fileInfos {
+			if fi.IsDir() || filepath.Ext(fi.Name()) != ".sql" {
+				continue
+			}
+
+			p := filepath.Join(dir, fi.Name())
+			body, err := s.options.MigratorFS.ReadFile(p)
+			if err != nil {
+				return err
+			}
+
+			name := strings.TrimPrefix(p, path+string(filepath.Separator))
+			t, err := tmpl.New(name).Parse(string(body))
+			if err != nil {
+				return err
+			}
+
+			var buf strings.Builder
+			err = t.Execute(&buf, s.Data)
+			if err != nil {
+				return err
+			}
+
+			s.Seeds = append(s.Seeds, &Seed{Name: name, SQL: strings.TrimSpace(buf.String())})
+		}
+	}
+
+	if len(s.Seeds) == 0 {
+		return NoMigrationsFoundError{Path: path}
+	}
+
+	return nil
+}
+
+// SeedsToApply returns the seeds that were never run or whose checksum
+// differs from the one recorded when they were last run.
+func (s *Seeder) SeedsToApply(ctx context.Context) ([]*Seed, error) {
+	rows, err := s.conn.Query(ctx, fmt.Sprintf("select seed_name, checksum from %s", s.seedTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[string]string)
+	for rows.Next() {
+		var name, checksum string
+		err = rows.Scan(&name, &checksum)
+		if err != nil {
+			return nil, err
+		}
+		applied[name] = checksum
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	toApply := []*Seed{}
+	for _, seed := range s.Seeds {
+		if applied[seed.Name] != seed.Checksum() {
+			toApply = append(toApply, seed)
+		}
+	}
+	return toApply, nil
+}
+
+// Seed runs the seeds returned by SeedsToApply, or all loaded seeds if force
+// is set. It calls s.OnStart when it begins a seed.
+func (s *Seeder) Seed(ctx context.Context, force bool) (err error) {
+	_, err = s.conn.Exec(ctx, "select pg_advisory_lock($1)", lockNum)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, unlockErr := s.conn.Exec(ctx, "select pg_advisory_unlock($1)", lockNum)
+		if err == nil && unlockErr != nil {
+			err = unlockErr
+		}
+	}()
+
+	seeds := s.Seeds
+	if !force {
+		seeds, err = s.SeedsToApply(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, seed := range seeds {
+		err = s.runSeed(ctx, seed)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Seeder) runSeed(ctx context.Context, seed *Seed) error {
+	var tx pgx.Tx
+	var err error
+	if !s.options.DisableTx {
+		tx, err = s.conn.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+	}
+
+	if s.OnStart != nil {
+		s.OnStart(seed.Name, seed.SQL)
+	}
+	if seed.SQL != "" {
+		_, err = s.conn.Exec(ctx, seed.SQL)
+		if err != nil {
+			if err, ok := err.(*pgconn.PgError); ok {
+				return MigrationPgError{Sql: seed.SQL, PgError: err}
+			}
+			return err
+		}
+	}
+
+	// Reset all database connection settings. Important to do before recording the seed as search_path may have been changed.
+	s.conn.Exec(ctx, "reset all")
+
+	_, err = s.conn.Exec(ctx, fmt.Sprintf(`
+	insert into %s (seed_name, checksum, seeded_at) values ($1, $2, now())
+	on conflict (seed_name) do update set checksum = excluded.checksum, seeded_at = excluded.seeded_at
+	`, s.seedTable), seed.Name, seed.Checksum())
+	if err != nil {
+		return err
+	}
+
+	if !s.options.DisableTx {
+		return tx.Commit(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MigrationState is whether a migration has been applied.
+type MigrationState string
+
+const (
+	StatePending MigrationState = "pending"
+	StateApplied MigrationState = "applied"
+	// StateMissing is an applied migration that is no longer loaded.
+	StateMissing MigrationState = "missing"
+)
+
+// ChecksumState compares the checksum recorded when a migration was applied
+// with the checksum of its rendered up SQL.
+type ChecksumState string
+
+const (
+	// ChecksumUnknown is set for migrations that are pending or were applied
+	// without a checksum, e.g. before pggo recorded checksums or as part of a
+	// squashed migration.
+	ChecksumUnknown  ChecksumState = "unknown"
+	ChecksumMatch    ChecksumState = "match"
+	ChecksumMismatch ChecksumState = "mismatch"
+)
+
+// MigrationStatus is the status of one migration in a StatusReport.
+type MigrationStatus struct {
+	Name        string
+	Description string
+	State       MigrationState
+	AppliedAt   time.Time // AppliedAt is zero for pending migrations
+	Reversible  bool      // Reversible is set for migrations with a down section
+	Checksum    ChecksumState
+
+	// Metadata from the header of the migration file, or from the version
+	// table for missing migrations.
+	Author  string
+	Ticket  string
+	Tags    []string
+	Options []string
+}
+
+// StatusReport is the status of the loaded migrations in the database.
+type StatusReport struct {
+	// Migrations are in the order they are applied, followed by missing
+	// migrations in the order they were applied.
+	Migrations []MigrationStatus
+	// Repeatables are sorted by name. A repeatable migration whose checksum
+	// changed is pending.
+	Repeatables []MigrationStatus
+
+	Applied            int
+	Pending            int
+	Missing            int
+	Modified           int // Modified counts applied migrations with a checksum mismatch
+	PendingRepeatables int
+}
+
+// UpToDate reports whether no migration is pending.
+func (r *StatusReport) UpToDate() bool {
+	return r.Pending == 0
+}
+
+// PendingMigrations returns the names of the pending migrations in the order
+// they are applied.
+func (r *StatusReport) PendingMigrations() []string {
+	names := make([]string, 0, r.Pending)
+	for _, migration := range r.Migrations {
+		if migration.State == StatePending {
+			names = append(names, migration.Name)
+		}
+	}
+	return names
+}
+
+// versionRow is a row of the version table.
+type versionRow struct {
+	name        string
+	appliedAt   time.Time
+	checksum    string
+	description string
+	author      string
+	ticket      string
+	tags        []string
+	options     []string
+}
+
+// Status compares the loaded migrations with the version table. Pending
+// migrations are the ones MigrationsToApply returns and pending repeatable
+// migrations the ones RepeatablesToApply returns.
+func (m *Migrator) Status(ctx context.Context) (*StatusReport, error) {
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	order, err := m.MigrationOrder()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := m.versionRows(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	replacedBy := make(map[string]*Migration)
+	for _, migration := range m.Migrations {
+		for _, name := range migration.Replaces {
+			replacedBy[name] = migration
+		}
+	}
+
+	report := &StatusReport{}
+	for _, name := range order {
+		migration := m.Migrations[name]
+		status := MigrationStatus{
+			Name:        name,
+			Description: migration.Description,
+			State:       StatePending,
+			Reversible:  migration.DownSQL != "",
+			Checksum:    ChecksumUnknown,
+			Author:      migration.Author,
+			Ticket:      migration.Ticket,
+			Tags:        migration.Tags,
+			Options:     migration.Options,
+		}
+
+		if Position(applied, name) >= 0 {
+			status.State = StateApplied
+			if row, ok := rows[name]; ok {
+				status.AppliedAt = row.appliedAt
+				status.Checksum = checksumState(row.checksum, migration)
+			} else if len(migration.Replaces) > 0 {
+				// Applied as the migrations it replaces, the last of which
+				// completed it.
+				status.AppliedAt = rows[migration.Replaces[len(migration.Replaces)-1]].appliedAt
+			}
+		}
+
+		switch {
+		case status.State == StatePending:
+			report.Pending++
+		case status.Checksum == ChecksumMismatch:
+			report.Applied++
+			report.Modified++
+		default:
+			report.Applied++
+		}
+		report.Migrations = append(report.Migrations, status)
+	}
+
+	var missing []versionRow
+	for name, row := range rows {
+		if _, ok := m.Migrations[name]; ok {
+			continue
+		}
+		if _, ok := replacedBy[name]; ok {
+			continue
+		}
+		missing = append(missing, row)
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		if missing[i].appliedAt.Equal(missing[j].appliedAt) {
+			return missing[i].name < missing[j].name
+		}
+		return missing[i].appliedAt.Before(missing[j].appliedAt)
+	})
+	for _, row := range missing {
+		report.Missing++
+		report.Migrations = append(report.Migrations, MigrationStatus{
+			Name:        row.name,
+			Description: row.description,
+			State:       StateMissing,
+			AppliedAt:   row.appliedAt,
+			Checksum:    ChecksumUnknown,
+			Author:      row.author,
+			Ticket:      row.ticket,
+			Tags:        row.tags,
+			Options:     row.options,
+		})
+	}
+
+	repeatableRows, err := m.versionRows(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(m.Repeatables))
+	for name := range m.Repeatables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		repeatable := m.Repeatables[name]
+		status := MigrationStatus{
+			Name:        name,
+			Description: repeatable.Description,
+			State:       StatePending,
+			Checksum:    ChecksumUnknown,
+			Author:      repeatable.Author,
+			Ticket:      repeatable.Ticket,
+			Tags:        repeatable.Tags,
+			Options:     repeatable.Options,
+		}
+		if row, ok := repeatableRows[name]; ok {
+			status.AppliedAt = row.appliedAt
+			status.Checksum = checksumState(row.checksum, repeatable)
+			if status.Checksum == ChecksumMatch {
+				status.State = StateApplied
+			}
+		}
+		if status.State == StatePending {
+			report.PendingRepeatables++
+		}
+		report.Repeatables = append(report.Repeatables, status)
+	}
+
+	return report, nil
+}
+
+// checksumState compares the recorded checksum of migration with its current
+// checksum.
+func checksumState(recorded string, migration *Migration) ChecksumState {
+	switch recorded {
+	case "":
+		return ChecksumUnknown
+	case migration.Checksum():
+		return ChecksumMatch
+	}
+	return ChecksumMismatch
+}
+
+// versionRows returns the rows of the version table for repeatable or
+// regular migrations by migration name.
+func (m *Migrator) versionRows(ctx context.Context, repeatable bool) (map[string]versionRow, error) {
+	rows, err := m.conn.Query(ctx,
+		fmt.Sprintf(`select migration_name, migrated_at, coalesce(checksum, ''), coalesce(description, ''),
+		coalesce(author, ''), coalesce(ticket, ''), tags, options
+		from %s where repeatable = $1`, m.versionTable),
+		repeatable,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[string]versionRow)
+	for rows.Next() {
+		var row versionRow
+		var appliedAt *time.Time
+		err = rows.Scan(&row.name, &appliedAt, &row.checksum, &row.description, &row.author, &row.ticket, &row.tags, &row.options)
+		if err != nil {
+			return nil, err
+		}
+		if appliedAt != nil {
+			row.appliedAt = *appliedAt
+		}
+		versions[row.name] = row
+	}
+	return versions, rows.Err()
+}
@@ -0,0 +1,8 @@
+-- depends-on: 002_create_users
+create table accounts(
+  user_id int references users
+);
+
+---- create above / drop below ----
+
+drop table accounts;
@@ -0,0 +1,7 @@
+create table users(
+  id serial primary key
+);
+
+---- create above / drop below ----
+
+drop table users;
@@ -0,0 +1,8 @@
+-- depends-on: 001_create_accounts.sql, 002_create_users.sql
+create table orders(
+  id serial primary key
+);
+
+---- create above / drop below ----
+
+drop table orders;
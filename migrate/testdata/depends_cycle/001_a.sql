@@ -0,0 +1,2 @@
+-- depends-on: 002_b
+create table a(id int);
@@ -0,0 +1,2 @@
+-- depends-on: 001_a
+create table b(id int);
@@ -0,0 +1 @@
+create table c(id int);
@@ -0,0 +1,2 @@
+-- depends-on: 005_missing.sql
+create table a(id int);
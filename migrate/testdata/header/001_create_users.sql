@@ -0,0 +1,16 @@
+-- description: Create the users table
+-- description: and its email index
+-- author: jane
+-- tags: users, auth
+-- tags: signup
+-- ticket: APP-123
+-- Note: the index is built later.
+-- reviewed-by: bob
+create table users(
+  id serial primary key,
+  email text not null
+);
+
+---- create above / drop below ----
+
+drop table users;
@@ -0,0 +1,7 @@
+-- description: Index users by email
+-- options: no-transaction
+create index concurrently users_email_idx on users(email);
+
+---- create above / drop below ----
+
+drop index concurrently users_email_idx;
@@ -0,0 +1,11 @@
+create table t1(
+  id serial primary key
+);
+alter table t1 owner to {{.owner | default "postgres"}};
+{{if .with_seed}}
+insert into t1 default values;
+{{end}}
+
+---- create above / drop below ----
+
+drop table if exists t1;
@@ -0,0 +1 @@
+create or replace view v1 as select * from t1;
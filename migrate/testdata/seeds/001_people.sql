@@ -0,0 +1 @@
+insert into people(name) values ('{{.admin}}') on conflict do nothing;
@@ -0,0 +1 @@
+insert into people(name) values ('dev') on conflict do nothing;
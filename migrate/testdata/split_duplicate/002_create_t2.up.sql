@@ -0,0 +1,3 @@
+create table t1(
+  id serial primary key
+);
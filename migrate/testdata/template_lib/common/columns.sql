@@ -0,0 +1,2 @@
+  id serial primary key,
+  changed_at timestamptz not null
@@ -0,0 +1,10 @@
+create table audit(
+{{ template "common/columns.sql" . }}
+);
+
+{{ template "shared/functions/audit.sql" . }}
+
+---- create above / drop below ----
+
+drop function {{.prefix}}audit();
+drop table audit;
@@ -0,0 +1,2 @@
+-- Seeds are not shared templates, parsing this one would fail.
+insert into audit_log(message) values ('{{');
@@ -0,0 +1,6 @@
+create function {{.prefix}}audit() returns trigger language plpgsql as $$
+begin
+  insert into audit(changed_at) values (now());
+  return new;
+end
+$$;
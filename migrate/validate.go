@@ -0,0 +1,174 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// ValidationError is a problem found by Validate.
+type ValidationError struct {
+	Path    string // Path is the file the problem was found in, empty for problems spanning several files
+	Message string
+	Warning bool // Warning is set for problems that do not stop the migrations from running
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// timestampDigits is the length of a timestamp prefix as created by pggo new,
+// e.g. 20261018073015. Gaps between such prefixes are expected.
+const timestampDigits = 14
+
+// noValue is what text/template renders for data that is not set. Templates
+// are rendered as the migrator renders them, so data a template only tests
+// with if or passes to default is not reported.
+const noValue = "<no value>"
+
+var errDataNotSet = errors.New("template uses data that is not set")
+
+// Validate loads the migrations in path without a database connection and
+// returns every problem found instead of stopping at the first one. Templates
+// are rendered with data.
+func Validate(path string, data map[string]interface{}, opts *MigratorOptions) []ValidationError {
+	path = strings.TrimRight(path, string(filepath.Separator))
+	m, err := NewMigratorEx(context.Background(), nil, "", opts)
+	if err != nil {
+		return []ValidationError{{Path: path, Message: err.Error()}}
+	}
+	if data != nil {
+		m.Data = data
+	}
+
+	var problems []ValidationError
+	report := func(path string, err error) {
+		problems = append(problems, ValidationError{Path: path, Message: err.Error()})
+	}
+	warn := func(path, message string) {
+		problems = append(problems, ValidationError{Path: path, Message: message, Warning: true})
+	}
+
+	files, fileProblems, err := scanMigrationFiles(path, m.options.MigratorFS)
+	if err != nil {
+		return []ValidationError{{Path: path, Message: err.Error()}}
+	}
+
+	mainTmpl := template.New("main").Funcs(TemplateFuncs())
+	err = loadSharedTemplates(m.options, mainTmpl, path)
+	if err != nil {
+		report(path, err)
+	}
+	for _, err := range fileProblems {
+		report("", err)
+	}
+
+	repeatablePaths, err := FindRepeatablesEx(path, m.options.MigratorFS)
+	if err != nil {
+		return append(problems, ValidationError{Path: path, Message: err.Error()})
+	}
+
+	if len(files) == 0 && len(repeatablePaths) == 0 && len(fileProblems) == 0 {
+		return append(problems, ValidationError{Path: path, Message: NoMigrationsFoundError{Path: path}.Error()})
+	}
+
+	fileInfos, err := m.options.MigratorFS.ReadDir(path)
+	if err != nil {
+		return append(problems, ValidationError{Path: path, Message: err.Error()})
+	}
+	for _, fi := range fileInfos {
+		if fi.IsDir() || filepath.Ext(fi.Name()) != ".sql" {
+			continue
+		}
+		if !migrationPattern.MatchString(fi.Name()) && !repeatablePattern.MatchString(fi.Name()) {
+			warn(filepath.Join(path, fi.Name()), "ignored, migration file names look like 001_name.sql or R_name.sql")
+		}
+	}
+
+	problems = append(problems, checkSequences(files)...)
+
+	for _, p := range repeatablePaths {
+		err = m.loadRepeatable(mainTmpl, p)
+		if err != nil {
+			report(p, err)
+			continue
+		}
+		if strings.Contains(m.Repeatables[filepath.Base(p)].UpSQL, noValue) {
+			report(p, errDataNotSet)
+		}
+	}
+
+	for _, f := range files {
+		err = m.loadMigration(mainTmpl, f)
+		if err != nil {
+			report(f.upPath, err)
+			continue
+		}
+
+		migration := m.Migrations[f.name]
+		if strings.Contains(migration.UpSQL, noValue) || strings.Contains(migration.DownSQL, noValue) {
+			report(f.upPath, errDataNotSet)
+		}
+		if migration.DownSQL == "" && len(migration.Replaces) == 0 {
+			warn(f.upPath, "no down migration, it cannot be rolled back")
+		}
+	}
+
+	_, err = m.MigrationOrder()
+	if err != nil {
+		report("", err)
+	}
+
+	return problems
+}
+
+// checkSequences reports migrations sharing a sequence number as errors and
+// gaps between sequence numbers as warnings. Timestamp prefixes are not
+// checked for gaps.
+func checkSequences(files []*migrationFile) []ValidationError {
+	bySequence := make(map[uint64][]string)
+	var sequences []uint64
+	for _, f := range files {
+		prefix := strings.SplitN(f.name, "_", 2)[0]
+		n, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, ok := bySequence[n]; !ok {
+			sequences = append(sequences, n)
+		}
+		bySequence[n] = append(bySequence[n], f.name)
+	}
+	sort.Slice(sequences, func(i, j int) bool { return sequences[i] < sequences[j] })
+
+	var problems []ValidationError
+	for i, n := range sequences {
+		names := bySequence[n]
+		if len(names) > 1 {
+			problems = append(problems, ValidationError{
+				Message: fmt.Sprintf("sequence number %d is used by %s", n, strings.Join(names, ", ")),
+			})
+		}
+
+		if i == 0 || len(strings.SplitN(names[0], "_", 2)[0]) >= timestampDigits {
+			continue
+		}
+		prev := sequences[i-1]
+		if n != prev+1 {
+			problems = append(problems, ValidationError{
+				Message: fmt.Sprintf("gap in sequence numbers between %s and %s", bySequence[prev][0], names[0]),
+				Warning: true,
+			})
+		}
+	}
+
+	return problems
+}
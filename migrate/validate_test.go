@@ -0,0 +1,45 @@
+package migrate_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/swelf19/pggo/v2/migrate"
+)
+
+func TestValidate(t *testing.T) {
+	opts := &migrate.MigratorOptions{}
+
+	problems := migrate.Validate("testdata/sample", map[string]interface{}{"prefix": "foo_"}, opts)
+	assert.Equal(t, []migrate.ValidationError{
+		{Path: "testdata/sample/should_be_ignored.sql", Message: "ignored, migration file names look like 001_name.sql or R_name.sql", Warning: true},
+		{Path: "testdata/sample/003_irreversible.sql", Message: "no down migration, it cannot be rolled back", Warning: true},
+	}, problems)
+
+	problems = migrate.Validate("testdata/templates", nil, &migrate.MigratorOptions{TemplatePaths: []string{"testdata/template_lib"}})
+	assert.Equal(t, []migrate.ValidationError{
+		{Path: "testdata/templates/001_create_audit.sql", Message: "template uses data that is not set"},
+	}, problems)
+
+	// Data a template only tests with if or passes to default may be unset.
+	problems = migrate.Validate("testdata/optional_data", nil, opts)
+	assert.Empty(t, problems)
+
+	problems = migrate.Validate("testdata/duplicate", nil, opts)
+	assert.Equal(t, []migrate.ValidationError{
+		{Message: "sequence number 2 is used by 002_create_t2.sql, 002_duplicate.sql"},
+	}, problems)
+
+	problems = migrate.Validate("testdata/gap", nil, opts)
+	assert.Contains(t, problems, migrate.ValidationError{Message: "gap in sequence numbers between 001_create_t1.sql and 003_irreversible.sql", Warning: true})
+
+	problems = migrate.Validate("testdata/noforward", nil, opts)
+	assert.Equal(t, []migrate.ValidationError{
+		{Path: "testdata/noforward/001_create_no_forward.sql", Message: migrate.ErrNoFwMigration.Error()},
+	}, problems)
+
+	problems = migrate.Validate("testdata/empty", nil, opts)
+	assert.Equal(t, []migrate.ValidationError{
+		{Path: "testdata/empty", Message: "No migrations found at testdata/empty"},
+	}, problems)
+}
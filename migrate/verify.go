@@ -0,0 +1,94 @@
+package migrate
+
+import (
+	"context"
+)
+
+// VerifyResult is the outcome of verifying one migration. Irreversible
+// migrations are not verified. DownChanges lists the catalog changes left
+// behind by running the down SQL after the up SQL, UpChanges those between the
+// first and the second run of the up SQL. Err is set when the down SQL or the
+// second run of the up SQL failed.
+type VerifyResult struct {
+	Migration    string
+	Irreversible bool
+	DownChanges  []CatalogChange
+	UpChanges    []CatalogChange
+	Err          error
+}
+
+// OK reports whether the down SQL of the migration reverses its up SQL.
+func (r VerifyResult) OK() bool {
+	return r.Irreversible || r.Err == nil && len(r.DownChanges) == 0 && len(r.UpChanges) == 0
+}
+
+// Verify applies the migrations one at a time to an empty database and checks
+// that each down SQL reverses its up SQL. After each migration is applied it
+// is rolled back and applied again, comparing catalog snapshots after each
+// step. Verification stops at the first migration whose down SQL or second up
+// SQL fails as the database is in an unknown state. Verify must only be run
+// against a scratch database.
+func (m *Migrator) Verify(ctx context.Context) (results []VerifyResult, err error) {
+	err = m.acquireAdvisoryLock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		unlockErr := m.releaseAdvisoryLock(ctx)
+		if err == nil && unlockErr != nil {
+			err = unlockErr
+		}
+	}()
+
+	order, err := m.MigrationOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := SnapshotCatalog(ctx, m.conn)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range order {
+		migration := m.Migrations[name]
+		err = m.applyMigration(ctx, migration, Forward)
+		if err != nil {
+			return results, err
+		}
+		after, err := SnapshotCatalog(ctx, m.conn)
+		if err != nil {
+			return results, err
+		}
+
+		result := VerifyResult{Migration: name, Irreversible: migration.DownSQL == ""}
+		if !result.Irreversible {
+			err = m.applyMigration(ctx, migration, Back)
+			if err != nil {
+				result.Err = err
+				return append(results, result), nil
+			}
+			down, err := SnapshotCatalog(ctx, m.conn)
+			if err != nil {
+				return results, err
+			}
+			result.DownChanges = before.Diff(down)
+
+			err = m.applyMigration(ctx, migration, Forward)
+			if err != nil {
+				result.Err = err
+				return append(results, result), nil
+			}
+			again, err := SnapshotCatalog(ctx, m.conn)
+			if err != nil {
+				return results, err
+			}
+			result.UpChanges = after.Diff(again)
+		}
+
+		results = append(results, result)
+		before = after
+	}
+
+	return results, nil
+}
@@ -1,36 +0,0 @@
-package main
-
-import (
-	"net"
-	"os"
-
-	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/agent"
-)
-
-type SSHConnConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-}
-
-func NewSSHClient(config *SSHConnConfig) (*ssh.Client, error) {
-	sshConfig := &ssh.ClientConfig{
-		User: config.User,
-		Auth: []ssh.AuthMethod{SSHAgent()},
-	}
-
-	if config.Password != "" {
-		sshConfig.Auth = append(sshConfig.Auth, ssh.Password(config.Password))
-	}
-
-	return ssh.Dial("tcp", net.JoinHostPort(config.Host, config.Port), sshConfig)
-}
-
-func SSHAgent() ssh.AuthMethod {
-	if sshAgent, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK")); err == nil {
-		return ssh.PublicKeysCallback(agent.NewClient(sshAgent).Signers)
-	}
-	return nil
-}